@@ -0,0 +1,204 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config loads the ecs_exporter configuration file, an alternative
+// to command line flags which can be reloaded without restarting the
+// exporter.
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"go.yaml.in/yaml/v2"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/output"
+	"github.com/prometheus-community/ecs_exporter/proxy"
+	"github.com/prometheus-community/ecs_exporter/relabel"
+)
+
+// Config is the configuration file. Settings left out of it keep the value
+// of the corresponding command line flag.
+type Config struct {
+	// Collectors enables or disables collectors by name.
+	Collectors map[string]bool `yaml:"collectors"`
+	Labels     LabelsConfig    `yaml:"labels"`
+	Containers ContainerConfig `yaml:"containers"`
+	Metadata   MetadataConfig  `yaml:"metadata"`
+	// MetricRelabelConfigs are applied to every metric before it is exposed.
+	MetricRelabelConfigs []*relabel.Config `yaml:"metric_relabel_configs"`
+	// Proxy scrapes the task's other containers to merge their metrics
+	// into the exporter's.
+	Proxy proxy.Config `yaml:"proxy"`
+	// Outputs push metrics elsewhere. On reload, changed outputs are
+	// restarted.
+	Outputs output.Config `yaml:"outputs"`
+}
+
+// LabelsConfig configures the labels added to metrics.
+type LabelsConfig struct {
+	// Task are the task-level labels to add to every metric.
+	Task []string `yaml:"task"`
+	// Container are the Docker labels to add to container-level metrics.
+	Container []ContainerLabelConfig `yaml:"container"`
+}
+
+// ContainerLabelConfig maps a Docker label to a metric label.
+type ContainerLabelConfig struct {
+	DockerLabel string `yaml:"docker_label"`
+	// Name defaults to container_label_<docker label>, sanitized.
+	Name string `yaml:"name"`
+}
+
+// ContainerConfig selects the containers to produce metrics for.
+type ContainerConfig struct {
+	Include      *Regexp  `yaml:"include"`
+	Exclude      *Regexp  `yaml:"exclude"`
+	IncludeTypes []string `yaml:"include_types"`
+	ExcludeTypes []string `yaml:"exclude_types"`
+	ExcludeSelf  *bool    `yaml:"exclude_self"`
+	// StoppedRetention is like the --containers.stopped-retention flag.
+	StoppedRetention *model.Duration `yaml:"stopped_retention"`
+}
+
+// MetadataConfig configures the queries to the task metadata endpoint.
+type MetadataConfig struct {
+	// TaskCacheTTL and StatsCacheTTL are how long task metadata and
+	// container stats responses are reused for. Zero disables caching.
+	TaskCacheTTL  model.Duration `yaml:"task_cache_ttl"`
+	StatsCacheTTL model.Duration `yaml:"stats_cache_ttl"`
+	// StatsTimestamps is like the --metadata.stats-timestamps flag.
+	StatsTimestamps *bool `yaml:"stats_timestamps"`
+}
+
+// Regexp is a regular expression matching whole strings, decoded from a
+// YAML string.
+type Regexp struct {
+	*regexp.Regexp
+	original string
+}
+
+func (re *Regexp) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	r, err := regexp.Compile("^(?:" + s + ")$")
+	if err != nil {
+		return err
+	}
+	re.Regexp = r
+	re.original = s
+	return nil
+}
+
+func (re Regexp) MarshalYAML() (interface{}, error) {
+	return re.original, nil
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *Config) validate() error {
+	known := make(map[string]bool)
+	for _, info := range ecscollector.AvailableCollectors() {
+		known[info.Name] = true
+	}
+	for name := range c.Collectors {
+		if !known[name] {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+	}
+	for _, l := range c.Labels.Container {
+		if l.DockerLabel == "" {
+			return fmt.Errorf("container label without docker_label")
+		}
+	}
+	// Label names are checked on their own here, and again against the
+	// command line flags when the collector is created.
+	return c.Apply(ecscollector.Opts{}).Validate()
+}
+
+// Apply returns opts with the settings of the configuration file applied.
+func (c *Config) Apply(opts ecscollector.Opts) ecscollector.Opts {
+	if len(c.Collectors) > 0 {
+		enabled := make(map[string]bool)
+		if opts.Collectors == nil {
+			for _, info := range ecscollector.AvailableCollectors() {
+				enabled[info.Name] = info.DefaultEnabled
+			}
+		}
+		for _, name := range opts.Collectors {
+			enabled[name] = true
+		}
+		for name, on := range c.Collectors {
+			enabled[name] = on
+		}
+		opts.Collectors = []string{}
+		for name, on := range enabled {
+			if on {
+				opts.Collectors = append(opts.Collectors, name)
+			}
+		}
+		sort.Strings(opts.Collectors)
+	}
+	if c.Labels.Task != nil {
+		opts.TaskLabels = c.Labels.Task
+	}
+	if c.Labels.Container != nil {
+		opts.ContainerLabels = nil
+		for _, l := range c.Labels.Container {
+			opts.ContainerLabels = append(opts.ContainerLabels, ecscollector.ContainerLabel{DockerLabel: l.DockerLabel, Name: l.Name})
+		}
+	}
+	if c.Containers.Include != nil {
+		opts.IncludeContainers = c.Containers.Include.Regexp
+	}
+	if c.Containers.Exclude != nil {
+		opts.ExcludeContainers = c.Containers.Exclude.Regexp
+	}
+	if c.Containers.IncludeTypes != nil {
+		opts.IncludeContainerTypes = c.Containers.IncludeTypes
+	}
+	if c.Containers.ExcludeTypes != nil {
+		opts.ExcludeContainerTypes = c.Containers.ExcludeTypes
+	}
+	if c.Containers.ExcludeSelf != nil {
+		opts.ExcludeSelf = *c.Containers.ExcludeSelf
+	}
+	if c.Containers.StoppedRetention != nil {
+		opts.StoppedRetention = time.Duration(*c.Containers.StoppedRetention)
+	}
+	if c.Metadata.StatsTimestamps != nil {
+		opts.StatsTimestamps = *c.Metadata.StatsTimestamps
+	}
+	return opts
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/relabel"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load("testdata/good.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Duration(c.Metadata.TaskCacheTTL); got != time.Minute {
+		t.Errorf("got task cache TTL %s, want 1m", got)
+	}
+	if len(c.MetricRelabelConfigs) != 1 || c.MetricRelabelConfigs[0].Action != relabel.Drop {
+		t.Errorf("got metric relabel configs %+v, want one drop rule", c.MetricRelabelConfigs)
+	}
+	if pg := c.Outputs.Pushgateway; pg == nil || pg.Job != "ecs_exporter" || time.Duration(pg.Interval) != time.Minute {
+		t.Errorf("got pushgateway output %+v, want the default job and interval", pg)
+	}
+
+	if p := c.Proxy; len(p.Targets) != 1 || !p.DiscoverContainers || time.Duration(p.Timeout) != 5*time.Second {
+		t.Errorf("got proxy %+v, want one target, discovery and the default timeout", p)
+	}
+
+	opts := c.Apply(ecscollector.Opts{
+		Collectors: []string{"container", "network", "task"},
+		TaskLabels: []string{"task_id"},
+	})
+	if want := []string{"blkio", "container", "task"}; !slices.Equal(opts.Collectors, want) {
+		t.Errorf("got collectors %v, want %v", opts.Collectors, want)
+	}
+	if want := []string{"cluster_name", "family"}; !slices.Equal(opts.TaskLabels, want) {
+		t.Errorf("got task labels %v, want %v", opts.TaskLabels, want)
+	}
+	wantLabels := []ecscollector.ContainerLabel{{DockerLabel: "app.version", Name: "version"}, {DockerLabel: "app.team"}}
+	if !slices.Equal(opts.ContainerLabels, wantLabels) {
+		t.Errorf("got container labels %v, want %v", opts.ContainerLabels, wantLabels)
+	}
+	for name, want := range map[string]bool{"ecs-exporter": true, "ecs-service-connect-abc": true, "my-ecs-exporter": false} {
+		if got := opts.ExcludeContainers.MatchString(name); got != want {
+			t.Errorf("exclude regexp matches %q: got %t, want %t", name, got, want)
+		}
+	}
+	if !opts.ExcludeSelf {
+		t.Error("expected exclude_self to apply")
+	}
+	if opts.StoppedRetention != 5*time.Minute {
+		t.Errorf("got stopped retention %s, want 5m", opts.StoppedRetention)
+	}
+	if opts.IncludeContainers != nil {
+		t.Error("expected include to be left unset")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config string
+		err    string
+	}{
+		"unknown field":      {"colectors: {}", "field colectors not found"},
+		"unknown collector":  {"collectors: {nope: true}", `unknown collector "nope"`},
+		"unknown task label": {"labels: {task: [nope]}", `unknown task label "nope"`},
+		"label conflict":     {"labels: {container: [{docker_label: a, name: container_name}]}", "conflicts with a built-in label"},
+		"missing label":      {"labels: {container: [{name: a}]}", "container label without docker_label"},
+		"bad regexp":         {"containers: {include: '('}", "missing closing )"},
+		"negative ttl":       {"metadata: {stats_cache_ttl: -1s}", "not a valid duration"},
+		"bad relabel action": {"metric_relabel_configs: [{action: nope}]", `unknown relabel action "nope"`},
+		"pushgateway url":    {"outputs: {pushgateway: {job: a}}", "pushgateway output requires url"},
+		"remote write url":   {"outputs: {remote_write: {queue: {capacity: 10}}}", "remote_write output requires url"},
+		"dogstatsd packet":   {"outputs: {dogstatsd: {max_packet_size: 0}}", "dogstatsd output requires a positive max_packet_size"},
+		"graphite address":   {"outputs: {graphite: {prefix: ecs.}}", "graphite output requires address"},
+		"influx url":         {"outputs: {influx: {tags: {env: prod}}}", "influx output requires url"},
+		"proxy target url":   {"proxy: {targets: [{url: localhost:8080}]}", "scheme must be http or https"},
+		"emf dimension":      {"outputs: {emf: {dimensions: [[Nope]]}}", `unknown emf dimension "Nope"`},
+		"otlp protocol":      {"outputs: {otlp: {endpoint: http://a, protocol: grpcs}}", `unknown otlp protocol "grpcs"`},
+		"zero interval":      {"outputs: {pushgateway: {url: http://a, interval: 0s}}", "pushgateway output requires a positive interval"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yml")
+			if err := os.WriteFile(path, []byte(tc.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("got error %v, want one containing %q", err, tc.err)
+			}
+		})
+	}
+}
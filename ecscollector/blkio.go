@@ -0,0 +1,64 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("blkio", "Container block device I/O.", false, newBlkioCollector)
+}
+
+type blkioCollector struct {
+	readBytesDesc  *prometheus.Desc
+	writeBytesDesc *prometheus.Desc
+}
+
+func newBlkioCollector(b descBuilder) subcollector {
+	return &blkioCollector{
+		readBytesDesc: b.container(
+			"ecs_container_blkio_read_bytes_total",
+			"Cumulative total size of block device reads in bytes, summed over all devices."),
+
+		writeBytesDesc: b.container(
+			"ecs_container_blkio_write_bytes_total",
+			"Cumulative total size of block device writes in bytes, summed over all devices."),
+	}
+}
+
+func (c *blkioCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.readBytesDesc
+	ch <- c.writeBytesDesc
+}
+
+func (c *blkioCollector) update(ch chan<- prometheus.Metric, s *scrape) {
+	s.eachContainer(func(container containerScrape, labelValues []string) {
+		var read, write uint64
+		// Docker reports "Read" and "Write" on cgroup v1, and "read" and
+		// "write" on cgroup v2.
+		for _, entry := range container.stats.BlkioStats.IoServiceBytesRecursive {
+			switch strings.ToLower(entry.Op) {
+			case "read":
+				read += entry.Value
+			case "write":
+				write += entry.Value
+			}
+		}
+		ch <- s.statsMetric(container, s.newCounter(c.readBytesDesc, float64(read), container.StartedAt, labelValues...))
+		ch <- s.statsMetric(container, s.newCounter(c.writeBytesDesc, float64(write), container.StartedAt, labelValues...))
+	})
+}
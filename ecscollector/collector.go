@@ -17,9 +17,14 @@ package ecscollector
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
 
-	"github.com/docker/docker/api/types/container"
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
 	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -32,337 +37,371 @@ const nanoseconds = 1 / 1.0e9
 // standard metrics use bytes.
 const mebibytes = 1024 * 1024
 
-var (
-	taskMetadataDesc = prometheus.NewDesc(
-		"ecs_task_metadata_info",
-		"ECS task metadata, sourced from the task metadata endpoint version 4.",
-		taskMetadataLabels, nil)
-
-	taskCpuLimitDesc = prometheus.NewDesc(
-		"ecs_task_cpu_limit_vcpus",
-		"Configured task CPU limit in vCPUs (1 vCPU = 1024 CPU units). This is optional when running on EC2; if no limit is set, this metric has no value.",
-		taskLabels, nil)
-
-	taskMemLimitDesc = prometheus.NewDesc(
-		"ecs_task_memory_limit_bytes",
-		"Configured task memory limit in bytes. This is optional when running on EC2; if no limit is set, this metric has no value.",
-		taskLabels, nil)
-
-	taskEphemeralStorageUsedDesc = prometheus.NewDesc(
-		"ecs_task_ephemeral_storage_used_bytes",
-		"Current Fargate task ephemeral storage usage in bytes.",
-		taskLabels, nil)
-
-	taskEphemeralStorageAllocatedDesc = prometheus.NewDesc(
-		"ecs_task_ephemeral_storage_allocated_bytes",
-		"Configured Fargate task ephemeral storage allocated size in bytes.",
-		taskLabels, nil)
-
-	taskImagePullStartDesc = prometheus.NewDesc(
-		"ecs_task_image_pull_start_timestamp_seconds",
-		"The time at which the task started pulling docker images for its containers.",
-		taskLabels, nil)
-
-	taskImagePullStopDesc = prometheus.NewDesc(
-		"ecs_task_image_pull_stop_timestamp_seconds",
-		"The time at which the task stopped (i.e. completed) pulling docker images for its containers.",
-		taskLabels, nil)
-
-	restartTotalDesc = prometheus.NewDesc(
-		"ecs_container_restarts_total",
-		"Cumulative total count of container restarts. Only has a value if the container has been configured to restart on failure.",
-		containerLabels, nil)
-
-	cpuTotalDesc = prometheus.NewDesc(
-		"ecs_container_cpu_usage_seconds_total",
-		"Cumulative total container CPU usage in seconds.",
-		containerLabels, nil)
-
-	memUsageDesc = prometheus.NewDesc(
-		"ecs_container_memory_usage_bytes",
-		"Current container memory usage in bytes.",
-		containerLabels, nil)
-
-	memLimitDesc = prometheus.NewDesc(
-		"ecs_container_memory_limit_bytes",
-		"Configured container memory limit in bytes, set from the container-level limit in the task definition if any, otherwise the task-level limit.",
-		containerLabels, nil)
-
-	memCacheSizeDesc = prometheus.NewDesc(
-		"ecs_container_memory_page_cache_size_bytes",
-		"Current container memory page cache size in bytes. This is not a subset of used bytes.",
-		containerLabels, nil)
-
-	networkRxBytesDesc = prometheus.NewDesc(
-		"ecs_network_receive_bytes_total",
-		"Cumulative total size of network packets received in bytes.",
-		networkLabels, nil)
-
-	networkRxPacketsDesc = prometheus.NewDesc(
-		"ecs_network_receive_packets_total",
-		"Cumulative total count of network packets received.",
-		networkLabels, nil)
-
-	networkRxDroppedDesc = prometheus.NewDesc(
-		"ecs_network_receive_packets_dropped_total",
-		"Cumulative total count of network packets dropped in receiving.",
-		networkLabels, nil)
-
-	networkRxErrorsDesc = prometheus.NewDesc(
-		"ecs_network_receive_errors_total",
-		"Cumulative total count of network errors in receiving.",
-		networkLabels, nil)
-
-	networkTxBytesDesc = prometheus.NewDesc(
-		"ecs_network_transmit_bytes_total",
-		"Cumulative total size of network packets transmitted in bytes.",
-		networkLabels, nil)
-
-	networkTxPacketsDesc = prometheus.NewDesc(
-		"ecs_network_transmit_packets_total",
-		"Cumulative total count of network packets transmitted.",
-		networkLabels, nil)
-
-	networkTxDroppedDesc = prometheus.NewDesc(
-		"ecs_network_transmit_packets_dropped_total",
-		"Cumulative total count of network packets dropped in transmit.",
-		networkLabels, nil)
-
-	networkTxErrorsDesc = prometheus.NewDesc(
-		"ecs_network_transmit_errors_total",
-		"Cumulative total count of network errors in transmit.",
-		networkLabels, nil)
-)
-
 var containerLabels = []string{
 	"container_name",
 }
 
 var taskLabels = []string{}
 
-var taskMetadataLabels = []string{
-	"cluster",
-	"task_arn",
-	"family",
-	"revision",
-	"desired_status",
-	"known_status",
-	"availability_zone",
-	"launch_type",
-}
-
 var networkLabels = []string{
 	"interface",
 }
 
+// subcollector produces one group of metrics, which can be enabled or
+// disabled as a whole.
+type subcollector interface {
+	describe(ch chan<- *prometheus.Desc)
+	update(ch chan<- prometheus.Metric, s *scrape)
+}
+
+type factory struct {
+	CollectorInfo
+	new func(b descBuilder) subcollector
+}
+
+// CollectorInfo describes a group of metrics produced by the Collector.
+type CollectorInfo struct {
+	Name           string
+	Help           string
+	DefaultEnabled bool
+}
+
+var factories = make(map[string]factory)
+
+// registerCollector makes a subcollector available by name. It is called
+// from the init function of the file implementing the subcollector.
+func registerCollector(name, help string, defaultEnabled bool, new func(b descBuilder) subcollector) {
+	factories[name] = factory{
+		CollectorInfo: CollectorInfo{Name: name, Help: help, DefaultEnabled: defaultEnabled},
+		new:           new,
+	}
+}
+
+// AvailableCollectors returns the groups of metrics the Collector can
+// produce, sorted by name.
+func AvailableCollectors() []CollectorInfo {
+	infos := make([]CollectorInfo, 0, len(factories))
+	for _, f := range factories {
+		infos = append(infos, f.CollectorInfo)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Opts configures a Collector.
+type Opts struct {
+	// Collectors are the names of the groups of metrics to produce. If nil,
+	// the groups enabled by default are produced.
+	Collectors []string
+	// TaskLabels are the names of task-level labels, from TaskLabels, to add
+	// to every metric.
+	TaskLabels []string
+	// ContainerLabels map Docker labels of containers to labels of every
+	// container-level metric. Containers without a Docker label get an empty
+	// value.
+	ContainerLabels []ContainerLabel
+
+	// IncludeContainers and ExcludeContainers, when set, select containers by
+	// matching their name. Metrics of containers not selected are dropped,
+	// and their stats are left out of task-level aggregates.
+	IncludeContainers *regexp.Regexp
+	ExcludeContainers *regexp.Regexp
+	// IncludeContainerTypes and ExcludeContainerTypes, when set, select
+	// containers by type, e.g. "NORMAL" or "SERVICE_CONNECT_RELAY".
+	IncludeContainerTypes []string
+	ExcludeContainerTypes []string
+	// ExcludeSelf drops the metrics of the container the exporter runs in,
+	// as identified by the container metadata endpoint.
+	ExcludeSelf bool
+
+	// StoppedRetention is how long to keep exposing the final counters of
+	// stopped containers for, from their last stats. Zero disables this.
+	StoppedRetention time.Duration
+
+	// StatsTimestamps sets the timestamp of container metrics derived from
+	// container stats to the time the stats were read by the ECS agent,
+	// instead of leaving it to the scrape time.
+	StatsTimestamps bool
+}
+
+// Collector queries the ECS metadata server for ECS task and container
+// metrics.
+type Collector struct {
+	client          *ecsmetadata.Client
+	logger          *slog.Logger
+	taskLabels      []string
+	containerLabels []ContainerLabel
+	filter          containerFilter
+	statsTimestamps bool
+	history         *containerHistory
+	collectors      map[string]subcollector
+	// host is set in daemon mode, see NewDaemonCollector.
+	host *hostTasks
+	// now is the clock the age of container stats is measured with.
+	now func() time.Time
+}
+
+// Validate checks opts for unknown collectors and task labels, and invalid
+// or conflicting container labels.
+func (opts Opts) Validate() error {
+	for _, name := range opts.Collectors {
+		if _, ok := factories[name]; !ok {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+	}
+	if err := validateTaskLabels(opts.TaskLabels); err != nil {
+		return err
+	}
+	_, err := containerLabelNames(opts.ContainerLabels, opts.TaskLabels)
+	return err
+}
+
 // NewCollector returns a new Collector that queries ECS metadata server
 // for ECS task and container metrics.
-func NewCollector(client *ecsmetadata.Client, logger *slog.Logger) prometheus.Collector {
-	return &collector{client: client, logger: logger}
+func NewCollector(client *ecsmetadata.Client, logger *slog.Logger, opts Opts) (*Collector, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	names := opts.Collectors
+	if names == nil {
+		for _, info := range AvailableCollectors() {
+			if info.DefaultEnabled {
+				names = append(names, info.Name)
+			}
+		}
+	}
+	containerLabelNames, _ := containerLabelNames(opts.ContainerLabels, opts.TaskLabels)
+	b := descBuilder{containerLabels: containerLabelNames, taskLabels: opts.TaskLabels, retainStopped: opts.StoppedRetention > 0}
+	collectors := make(map[string]subcollector)
+	for _, name := range names {
+		collectors[name] = factories[name].new(b)
+	}
+	return &Collector{
+		client:          client,
+		logger:          logger,
+		taskLabels:      opts.TaskLabels,
+		containerLabels: opts.ContainerLabels,
+		filter:          newContainerFilter(opts),
+		statsTimestamps: opts.StatsTimestamps,
+		history:         newContainerHistory(opts.StoppedRetention),
+		collectors:      collectors,
+		now:             time.Now,
+	}, nil
+}
+
+// KeepHistory makes c carry on with the last stats of the containers seen by
+// prev, e.g. the Collector it replaces when the configuration is reloaded, so
+// that stopped containers keep their grace period. It has no effect unless c
+// retains stopped containers.
+func (c *Collector) KeepHistory(prev *Collector) {
+	if prev == nil || c.history == nil {
+		return
+	}
+	switch {
+	case c.host != nil && prev.host != nil:
+		c.host.keepHistory(prev.host)
+	case c.host == nil && prev.host == nil && prev.history != nil:
+		prev.history.setRetention(c.history.retention)
+		c.history = prev.history
+	}
 }
 
-type collector struct {
-	client *ecsmetadata.Client
-	logger *slog.Logger
+// Filter returns a Collector producing only the named groups of metrics,
+// which must be enabled on c.
+func (c *Collector) Filter(names []string) (*Collector, error) {
+	collectors := make(map[string]subcollector)
+	for _, name := range names {
+		sc, ok := c.collectors[name]
+		if !ok {
+			return nil, fmt.Errorf("collector %q is not enabled", name)
+		}
+		collectors[name] = sc
+	}
+	filtered := *c
+	filtered.collectors = collectors
+	return &filtered, nil
 }
 
-func (c *collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- taskMetadataDesc
-	ch <- taskCpuLimitDesc
-	ch <- taskMemLimitDesc
-	ch <- taskEphemeralStorageUsedDesc
-	ch <- taskEphemeralStorageAllocatedDesc
-	ch <- taskImagePullStartDesc
-	ch <- taskImagePullStopDesc
-	ch <- restartTotalDesc
-	ch <- cpuTotalDesc
-	ch <- memUsageDesc
-	ch <- memLimitDesc
-	ch <- memCacheSizeDesc
-	ch <- networkRxBytesDesc
-	ch <- networkRxPacketsDesc
-	ch <- networkRxDroppedDesc
-	ch <- networkRxErrorsDesc
-	ch <- networkTxBytesDesc
-	ch <- networkTxPacketsDesc
-	ch <- networkTxDroppedDesc
-	ch <- networkTxErrorsDesc
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, sc := range c.collectors {
+		sc.describe(ch)
+	}
 }
 
-func (c *collector) Collect(ch chan<- prometheus.Metric) {
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	if c.host != nil {
+		c.host.collect(ch, c)
+		return
+	}
 	ctx := context.Background()
 	metadata, err := c.client.RetrieveTaskMetadata(ctx)
 	if err != nil {
 		c.logger.Debug("Failed to retrieve metadata", "error", err)
 		return
 	}
+	if metadata.TaskResponse == nil {
+		c.logger.Debug("Got empty ECS task metadata response")
+		return
+	}
 	c.logger.Debug("Got ECS task metadata response", "metadata", metadata)
 
-	ch <- prometheus.MustNewConstMetric(
-		taskMetadataDesc,
-		prometheus.GaugeValue,
-		1.0,
-		metadata.Cluster,
-		metadata.TaskARN,
-		metadata.Family,
-		metadata.Revision,
-		metadata.DesiredStatus,
-		metadata.KnownStatus,
-		metadata.AvailabilityZone,
-		metadata.LaunchType,
-	)
-
-	// Task CPU/memory limits are optional when running on EC2 - the relevant
-	// limits may only exist at the container level.
-	if metadata.Limits != nil {
-		if metadata.Limits.CPU != nil {
-			ch <- prometheus.MustNewConstMetric(
-				taskCpuLimitDesc,
-				prometheus.GaugeValue,
-				*metadata.Limits.CPU,
-			)
-		}
-		if metadata.Limits.Memory != nil {
-			ch <- prometheus.MustNewConstMetric(
-				taskMemLimitDesc,
-				prometheus.GaugeValue,
-				float64(*metadata.Limits.Memory*mebibytes),
-			)
+	s := &scrape{
+		ctx:             ctx,
+		client:          c.client,
+		logger:          c.logger,
+		metadata:        metadata,
+		containerLabels: c.containerLabels,
+		filter:          c.filter,
+		statsTimestamps: c.statsTimestamps,
+		history:         c.history,
+		now:             c.now(),
+	}
+	if c.filter.self != nil {
+		if s.selfID, err = c.filter.self.get(ctx, c.client); err != nil {
+			c.logger.Debug("Failed to retrieve the exporter's container metadata", "error", err)
 		}
 	}
-
-	if metadata.EphemeralStorageMetrics != nil {
-		ch <- prometheus.MustNewConstMetric(
-			taskEphemeralStorageUsedDesc,
-			prometheus.GaugeValue,
-			float64(metadata.EphemeralStorageMetrics.UtilizedMiBs*mebibytes),
-		)
-		ch <- prometheus.MustNewConstMetric(
-			taskEphemeralStorageAllocatedDesc,
-			prometheus.GaugeValue,
-			float64(metadata.EphemeralStorageMetrics.ReservedMiBs*mebibytes),
-		)
+	for _, name := range c.taskLabels {
+		s.taskLabelValues = append(s.taskLabelValues, taskLabelValues[name](metadata))
 	}
-
-	if metadata.PullStartedAt != nil {
-		ch <- prometheus.MustNewConstMetric(
-			taskImagePullStartDesc,
-			prometheus.GaugeValue,
-			float64(metadata.PullStartedAt.UnixNano())*nanoseconds,
-		)
+	for _, sc := range c.collectors {
+		sc.update(ch, s)
 	}
-	if metadata.PullStoppedAt != nil {
-		ch <- prometheus.MustNewConstMetric(
-			taskImagePullStopDesc,
-			prometheus.GaugeValue,
-			float64(metadata.PullStoppedAt.UnixNano())*nanoseconds,
-		)
+}
+
+// scrape is the state of the task as seen by a single Collect call, shared by
+// all subcollectors.
+type scrape struct {
+	ctx      context.Context
+	client   *ecsmetadata.Client
+	logger   *slog.Logger
+	metadata *tmdsv4.TaskResponse
+	// taskLabelValues are the values of the Collector's task labels.
+	taskLabelValues []string
+	containerLabels []ContainerLabel
+	filter          containerFilter
+	// selfID is the ID of the exporter's container, if it is to be excluded.
+	selfID          string
+	statsTimestamps bool
+	history         *containerHistory
+	now             time.Time
+
+	statsRetrieved bool
+	containers     []containerScrape
+}
+
+// containerScrape is a container with its stats.
+type containerScrape struct {
+	tmdsv4.ContainerResponse
+	stats *tmdsv4.StatsResponse
+	// stopped is set if the container is stopped, and stats are the last
+	// ones it had, retained for the Collector's grace period.
+	stopped bool
+}
+
+// runningContainers returns the containers that have stats, including
+// recently stopped containers with retained stats, and pass the Collector's
+// container filter. Container stats
+// are only retrieved the first time this is called, so that they aren't
+// retrieved at all if no enabled subcollector needs them.
+func (s *scrape) runningContainers() []containerScrape {
+	if s.statsRetrieved {
+		return s.containers
 	}
+	s.statsRetrieved = true
 
-	stats, err := c.client.RetrieveTaskStats(ctx)
+	stats, err := s.client.RetrieveTaskStats(s.ctx)
 	if err != nil {
-		c.logger.Debug("Failed to retrieve container stats", "error", err)
-		return
+		s.logger.Debug("Failed to retrieve container stats", "error", err)
+		return nil
 	}
-	c.logger.Debug("Got ECS task stats response", "stats", stats)
+	s.logger.Debug("Got ECS task stats response", "stats", stats)
 
-	networks := make(map[string]*container.NetworkStats)
-	for _, container := range metadata.Containers {
-		s := stats[container.ID]
-		if s == nil || s.StatsJSON == nil {
-			// This can happen if the container is stopped; if it's
-			// nonessential, the task goes on.
-			c.logger.Debug("Couldn't find stats for container", "id", container.ID)
-			continue
+	var ids []string
+	for _, container := range s.metadata.Containers {
+		if container.ContainerResponse != nil {
+			ids = append(ids, container.ID)
 		}
+	}
+	stats, stopped := s.history.observe(ids, stats, s.now)
 
-		containerLabelVals := []string{
-			container.Name,
+	for _, container := range s.metadata.Containers {
+		if container.ContainerResponse == nil {
+			s.logger.Debug("Skipping empty container in ECS task metadata response")
+			continue
 		}
-
-		if container.RestartCount != nil {
-			ch <- prometheus.MustNewConstMetric(
-				restartTotalDesc,
-				prometheus.CounterValue,
-				float64(*container.RestartCount),
-				containerLabelVals...,
-			)
+		if !s.filter.match(container, s.selfID) {
+			s.logger.Debug("Skipping filtered container", "id", container.ID, "name", container.Name)
+			continue
 		}
-
-		ch <- prometheus.MustNewConstMetric(
-			cpuTotalDesc,
-			prometheus.CounterValue,
-			float64(s.CPUStats.CPUUsage.TotalUsage)*nanoseconds,
-			containerLabelVals...,
-		)
-
-		cacheValue := 0.0
-		if val, ok := s.MemoryStats.Stats["cache"]; ok {
-			cacheValue = float64(val)
+		cs := stats[container.ID]
+		if cs == nil || cs.StatsJSON == nil {
+			// This can happen if the container is stopped; if it's
+			// nonessential, the task goes on.
+			s.logger.Debug("Couldn't find stats for container", "id", container.ID)
+			continue
 		}
+		s.containers = append(s.containers, containerScrape{ContainerResponse: container, stats: cs, stopped: stopped[container.ID]})
+	}
+	return s.containers
+}
 
-		// Report the container's memory limit as its own, if any, otherwise the
-		// task's limit. This is correct in that this is the precise logic used
-		// to configure the cgroups limit for the container.
-		var containerMemoryLimitMib int64
-		if container.Limits.Memory != nil {
-			containerMemoryLimitMib = *container.Limits.Memory
-		} else {
-			// This must be set if the container limit is not set, and thus is
-			// safe to dereference.
-			containerMemoryLimitMib = *metadata.Limits.Memory
-		}
-		for desc, value := range map[*prometheus.Desc]float64{
-			memUsageDesc:     float64(s.MemoryStats.Usage),
-			memLimitDesc:     float64(containerMemoryLimitMib * mebibytes),
-			memCacheSizeDesc: cacheValue,
-		} {
-			ch <- prometheus.MustNewConstMetric(
-				desc,
-				prometheus.GaugeValue,
-				value,
-				containerLabelVals...,
-			)
-		}
+// eachContainer calls fn for each running container. A panic in fn, e.g. on a
+// response breaking our assumptions about its structure, only loses the
+// metrics of the container at hand.
+func (s *scrape) eachContainer(fn func(c containerScrape, labelValues []string)) {
+	for _, c := range s.runningContainers() {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					s.logger.Error("Panic while collecting container metrics", "id", c.ID, "panic", r)
+				}
+			}()
+			labelValues := []string{c.Name}
+			for _, l := range s.containerLabels {
+				labelValues = append(labelValues, c.Labels[l.DockerLabel])
+			}
+			fn(c, labelValues)
+		}()
+	}
+}
 
-		// Network metrics per interface.
-		for iface, netStats := range s.Networks {
-			// While the API response attaches network stats to each container,
-			// the container is in fact not a relevant dimension; only the
-			// interface is. This means that if multiple containers use the same
-			// network (extremely likely), we are redundantly writing this
-			// metric with "last one wins" semantics. This is fine: the values
-			// for an interface are the same across all containers.
-			//
-			// The collection process will error if you report the same metric
-			// multiple times, however, so we have to stash this data in the
-			// `netStats` map to ensure that we only send one metric per
-			// interface.
-			networks[iface] = &netStats
-		}
+// newMetric returns a metric of the scrape, with the values of the task labels
+// following labelValues.
+func (s *scrape) newMetric(desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) prometheus.Metric {
+	return newMetric(desc, valueType, value, slices.Concat(labelValues, s.taskLabelValues)...)
+}
+
+// statsMetric returns m, a metric derived from the stats of container c,
+// timestamped with the time the stats were read if the Collector is
+// configured to.
+func (s *scrape) statsMetric(c containerScrape, m prometheus.Metric) prometheus.Metric {
+	if !s.statsTimestamps || c.stats.Read.IsZero() {
+		return m
 	}
+	return prometheus.NewMetricWithTimestamp(c.stats.Read, m)
+}
 
-	for iface, netStats := range networks {
-		networkLabelVals := []string{
-			iface,
-		}
+// newCounter is like newMetric for a counter, with the time it started
+// counting from as its created timestamp, unless created is nil.
+func (s *scrape) newCounter(desc *prometheus.Desc, value float64, created *time.Time, labelValues ...string) prometheus.Metric {
+	if created == nil || created.IsZero() {
+		return s.newMetric(desc, prometheus.CounterValue, value, labelValues...)
+	}
+	m, err := prometheus.NewConstMetricWithCreatedTimestamp(desc, prometheus.CounterValue, value, *created, slices.Concat(labelValues, s.taskLabelValues)...)
+	if err != nil {
+		return prometheus.NewInvalidMetric(desc, err)
+	}
+	return m
+}
 
-		for desc, value := range map[*prometheus.Desc]float64{
-			networkRxBytesDesc:   float64(netStats.RxBytes),
-			networkRxPacketsDesc: float64(netStats.RxPackets),
-			networkRxDroppedDesc: float64(netStats.RxDropped),
-			networkRxErrorsDesc:  float64(netStats.RxErrors),
-			networkTxBytesDesc:   float64(netStats.TxBytes),
-			networkTxPacketsDesc: float64(netStats.TxPackets),
-			networkTxDroppedDesc: float64(netStats.TxDropped),
-			networkTxErrorsDesc:  float64(netStats.TxErrors),
-		} {
-			ch <- prometheus.MustNewConstMetric(
-				desc,
-				prometheus.CounterValue,
-				value,
-				networkLabelVals...,
-			)
-		}
+// newMetric is like prometheus.MustNewConstMetric, but returns an invalid
+// metric instead of panicking, so that the error is reported when the metric
+// is gathered.
+func newMetric(desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) prometheus.Metric {
+	m, err := prometheus.NewConstMetric(desc, valueType, value, labelValues...)
+	if err != nil {
+		return prometheus.NewInvalidMetric(desc, err)
 	}
+	return m
 }
@@ -23,38 +23,35 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+	"github.com/docker/docker/api/types/container"
 	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // Create a metadata client that will always receive the given fixture API
 // responses.
-func fixtureClient(taskMetadataPath, taskStatsPath string) (*ecsmetadata.Client, *httptest.Server, error) {
-	taskMetadata, err := os.ReadFile(taskMetadataPath)
+func fixtureClient(taskMetadataPath, taskStatsPath string) (*ecsmetadata.Client, *ecsmetadatatest.Server, error) {
+	fixture, err := ecsmetadatatest.LoadFixture(taskMetadataPath, taskStatsPath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read task metadata fixture: %w", err)
+		return nil, nil, err
 	}
-	taskStats, err := os.ReadFile(taskStatsPath)
+	server, err := ecsmetadatatest.NewServer(fixture)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read task stats fixture: %w", err)
+		return nil, nil, err
 	}
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("GET /task", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("content-type", "application/json")
-		w.Write(taskMetadata)
-	})
-	mux.HandleFunc("GET /task/stats", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("content-type", "application/json")
-		w.Write(taskStats)
-	})
-
-	server := httptest.NewServer(mux)
-	return ecsmetadata.NewClient(server.URL), server, nil
+	return server.Client(), server, nil
 }
 
 // Renders metrics from the given collector to the prometheus text exposition
@@ -113,28 +110,491 @@ func assertSnapshot(t *testing.T, collector prometheus.Collector, path string) {
 	}
 }
 
-func TestFargateMetrics(t *testing.T) {
-	metadataClient, metadataServer, err := fixtureClient(
+// TestSnapshots checks the metrics rendered for every fixture in
+// testdata/fixtures, as saved by `ecs_exporter record`, against the snapshot
+// of the same name in testdata/snapshots.
+func TestSnapshots(t *testing.T) {
+	const suffix = "_task_metadata.json"
+	fixtures, err := filepath.Glob(filepath.Join("testdata/fixtures", "*"+suffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), suffix)
+		t.Run(name, func(t *testing.T) {
+			metadataClient, metadataServer, err := fixtureClient(
+				fixture,
+				filepath.Join("testdata/fixtures", name+"_task_stats.json"),
+			)
+			if err != nil {
+				t.Fatalf("failed to load test fixtures: %v", err)
+			}
+			defer metadataServer.Close()
+			collector, err := NewCollector(metadataClient, slog.Default(), Opts{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Measure the age of stats from when the fixture was captured.
+			collector.now = metadataServer.Now
+			assertSnapshot(t, collector, filepath.Join("testdata/snapshots", name+"_metrics.txt"))
+		})
+	}
+}
+
+func TestCollectorSelection(t *testing.T) {
+	fixture, err := ecsmetadatatest.LoadFixture(
+		"testdata/fixtures/ec2_task_metadata.json",
+		"testdata/fixtures/ec2_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ecsmetadatatest.NewServer(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if err := server.SetHealth("prometheus", "UNHEALTHY"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewCollector(server.Client(), slog.Default(), Opts{Collectors: []string{"nope"}}); err == nil {
+		t.Error("expected error for unknown collector")
+	}
+
+	collector, err := NewCollector(server.Client(), slog.Default(), Opts{Collectors: []string{"blkio", "health"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# HELP ecs_container_blkio_read_bytes_total Cumulative total size of block device reads in bytes, summed over all devices.
+# TYPE ecs_container_blkio_read_bytes_total counter
+ecs_container_blkio_read_bytes_total{container_name="ecs-exporter"} 6.0960768e+07
+ecs_container_blkio_read_bytes_total{container_name="prometheus"} 9.9926016e+07
+# HELP ecs_container_blkio_write_bytes_total Cumulative total size of block device writes in bytes, summed over all devices.
+# TYPE ecs_container_blkio_write_bytes_total counter
+ecs_container_blkio_write_bytes_total{container_name="ecs-exporter"} 57344
+ecs_container_blkio_write_bytes_total{container_name="prometheus"} 274432
+# HELP ecs_container_health_status Current container health check status, 1 for the status the container is in and 0 for the others. Only has a value if the container has a health check configured.
+# TYPE ecs_container_health_status gauge
+ecs_container_health_status{container_name="prometheus",status="HEALTHY"} 0
+ecs_container_health_status{container_name="prometheus",status="UNHEALTHY"} 1
+ecs_container_health_status{container_name="prometheus",status="UNKNOWN"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	client, server, err := fixtureClient(
 		"testdata/fixtures/fargate_task_metadata.json",
 		"testdata/fixtures/fargate_task_stats.json",
 	)
 	if err != nil {
-		t.Fatalf("failed to load test fixtures: %v", err)
+		t.Fatal(err)
+	}
+	defer server.Close()
+	collector, err := NewCollector(client, slog.Default(), Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := collector.Filter([]string{"blkio"}); err == nil {
+		t.Error("expected error filtering on a disabled collector")
+	}
+	filtered, err := collector.Filter([]string{"task"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.CollectAndCount(filtered); got != 7 {
+		t.Errorf("got %d task metrics, want 7", got)
+	}
+	// Task metrics only need the task metadata.
+	if got := server.Requests("/task/stats"); got != 0 {
+		t.Errorf("got %d requests for container stats, want 0", got)
 	}
-	defer metadataServer.Close()
-	collector := NewCollector(metadataClient, slog.Default())
-	assertSnapshot(t, collector, "testdata/snapshots/fargate_metrics.txt")
 }
 
-func TestEc2Metrics(t *testing.T) {
-	metadataClient, metadataServer, err := fixtureClient(
-		"testdata/fixtures/ec2_task_metadata.json",
-		"testdata/fixtures/ec2_task_stats.json",
+func TestTaskLabels(t *testing.T) {
+	client, server, err := fixtureClient(
+		"testdata/fixtures/fargate_task_metadata.json",
+		"testdata/fixtures/fargate_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if _, err := NewCollector(client, slog.Default(), Opts{TaskLabels: []string{"nope"}}); err == nil {
+		t.Error("expected error for unknown task label")
+	}
+
+	collector, err := NewCollector(client, slog.Default(), Opts{
+		Collectors: []string{"task", "container_cpu"},
+		TaskLabels: []string{"cluster_name", "family", "task_id", "account_id", "region"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# HELP ecs_container_cpu_usage_seconds_total Cumulative total container CPU usage in seconds.
+# TYPE ecs_container_cpu_usage_seconds_total counter
+ecs_container_cpu_usage_seconds_total{account_id="829490980523",cluster_name="prom-ecs-exporter-sandbox",container_name="ecs-exporter",family="prom-ecs-exporter-sandbox-main-fargate",region="us-east-1",task_id="bae32def0ab64f06818e8862e58f8d6d"} 0.322633383
+ecs_container_cpu_usage_seconds_total{account_id="829490980523",cluster_name="prom-ecs-exporter-sandbox",container_name="prometheus",family="prom-ecs-exporter-sandbox-main-fargate",region="us-east-1",task_id="bae32def0ab64f06818e8862e58f8d6d"} 0.9324394920000001
+# HELP ecs_task_metadata_info ECS task metadata, sourced from the task metadata endpoint version 4.
+# TYPE ecs_task_metadata_info gauge
+ecs_task_metadata_info{account_id="829490980523",availability_zone="us-east-1a",cluster="arn:aws:ecs:us-east-1:829490980523:cluster/prom-ecs-exporter-sandbox",cluster_name="prom-ecs-exporter-sandbox",desired_status="RUNNING",family="prom-ecs-exporter-sandbox-main-fargate",known_status="RUNNING",launch_type="FARGATE",region="us-east-1",revision="9",task_arn="arn:aws:ecs:us-east-1:829490980523:task/prom-ecs-exporter-sandbox/bae32def0ab64f06818e8862e58f8d6d",task_id="bae32def0ab64f06818e8862e58f8d6d"} 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"ecs_container_cpu_usage_seconds_total", "ecs_task_metadata_info"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestContainerLabels(t *testing.T) {
+	client, server, err := fixtureClient(
+		"testdata/fixtures/fargate_task_metadata.json",
+		"testdata/fixtures/fargate_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Update(func(f *ecsmetadatatest.Fixture) {
+		for _, c := range f.TaskMetadata.Containers {
+			if c.Name == "prometheus" {
+				c.Labels["app.version"] = "v3.2.0"
+			}
+		}
+	})
+
+	for _, labels := range [][]ContainerLabel{
+		{{DockerLabel: "app.version", Name: "container_name"}},
+		{{DockerLabel: "app.version", Name: "family"}},
+		{{DockerLabel: "app-version"}, {DockerLabel: "app.version"}},
+		{{DockerLabel: "app.version", Name: "app.version"}},
+	} {
+		if _, err := NewCollector(client, slog.Default(), Opts{ContainerLabels: labels, TaskLabels: []string{"family"}}); err == nil {
+			t.Errorf("expected error for container labels %v", labels)
+		}
+	}
+
+	collector, err := NewCollector(client, slog.Default(), Opts{
+		Collectors: []string{"container_cpu"},
+		ContainerLabels: []ContainerLabel{
+			{DockerLabel: "app.version"},
+			{DockerLabel: "com.amazonaws.ecs.task-definition-version", Name: "revision"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# HELP ecs_container_cpu_usage_seconds_total Cumulative total container CPU usage in seconds.
+# TYPE ecs_container_cpu_usage_seconds_total counter
+ecs_container_cpu_usage_seconds_total{container_label_app_version="",container_name="ecs-exporter",revision="9"} 0.322633383
+ecs_container_cpu_usage_seconds_total{container_label_app_version="v3.2.0",container_name="prometheus",revision="9"} 0.9324394920000001
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestContainerFilter(t *testing.T) {
+	client, server, err := fixtureClient(
+		"testdata/fixtures/fargate_task_metadata.json",
+		"testdata/fixtures/fargate_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if err := server.SetSelf("ecs-exporter"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts Opts
+		want []string
+	}{
+		{"exclude self", Opts{ExcludeSelf: true}, []string{"prometheus"}},
+		{"exclude name", Opts{ExcludeContainers: regexp.MustCompile("^(?:prom.*)$")}, []string{"ecs-exporter"}},
+		{"include name", Opts{IncludeContainers: regexp.MustCompile("^(?:prom.*)$")}, []string{"prometheus"}},
+		{"include type", Opts{IncludeContainerTypes: []string{"NORMAL"}}, []string{"ecs-exporter", "prometheus"}},
+		{"exclude type", Opts{ExcludeContainerTypes: []string{"NORMAL"}}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Collectors = []string{"container_cpu", "network"}
+			collector, err := NewCollector(client, slog.Default(), tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			reg := prometheus.NewPedanticRegistry()
+			reg.MustRegister(collector)
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var network bool
+			for _, mf := range families {
+				if strings.HasPrefix(mf.GetName(), "ecs_network_") {
+					network = true
+				}
+				if mf.GetName() != "ecs_container_cpu_usage_seconds_total" {
+					continue
+				}
+				for _, m := range mf.GetMetric() {
+					got = append(got, m.GetLabel()[0].GetValue())
+				}
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got containers %v, want %v", got, tc.want)
+			}
+			// Network stats are per container, so they go with the containers.
+			if network != (len(tc.want) > 0) {
+				t.Errorf("got network metrics %t, want %t", network, len(tc.want) > 0)
+			}
+		})
+	}
+}
+
+func TestCreatedTimestamps(t *testing.T) {
+	client, server, err := fixtureClient(
+		"testdata/fixtures/fargate_task_metadata.json",
+		"testdata/fixtures/fargate_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Advance(time.Minute)
+	if err := server.RestartContainer("prometheus"); err != nil {
+		t.Fatal(err)
+	}
+
+	collector, err := NewCollector(client, slog.Default(), Opts{Collectors: []string{"container", "container_cpu"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := make(map[string]time.Time)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			created[mf.GetName()+"/"+m.GetLabel()[0].GetValue()] = m.GetCounter().GetCreatedTimestamp().AsTime()
+		}
+	}
+	for key, want := range map[string]time.Time{
+		// CPU usage restarts from zero with the container.
+		"ecs_container_cpu_usage_seconds_total/prometheus":   server.Now(),
+		"ecs_container_cpu_usage_seconds_total/ecs-exporter": time.Date(2025, 2, 27, 5, 6, 19, 394790335, time.UTC),
+		// Restarts are counted since the container was created.
+		"ecs_container_restarts_total/prometheus": time.Date(2025, 2, 27, 5, 6, 19, 179996393, time.UTC),
+	} {
+		if got, ok := created[key]; !ok || !got.Equal(want) {
+			t.Errorf("%s: got created timestamp %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestStatsTimestamps(t *testing.T) {
+	client, server, err := fixtureClient(
+		"testdata/fixtures/fargate_task_metadata.json",
+		"testdata/fixtures/fargate_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Advance(10 * time.Second)
+	read := server.Now()
+
+	collector, err := NewCollector(client, slog.Default(), Opts{
+		Collectors:      []string{"container", "container_cpu", "container_memory"},
+		StatsTimestamps: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The agent stopped refreshing stats 30s ago.
+	collector.now = func() time.Time { return read.Add(30 * time.Second) }
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			// Only metrics derived from stats are timestamped.
+			var want int64
+			switch mf.GetName() {
+			case "ecs_container_cpu_usage_seconds_total", "ecs_container_memory_usage_bytes", "ecs_container_memory_page_cache_size_bytes":
+				want = read.UnixMilli()
+			case "ecs_container_stats_age_seconds":
+				if got := m.GetGauge().GetValue(); got != 30 {
+					t.Errorf("got stats age %v, want 30", got)
+				}
+			}
+			if got := m.GetTimestampMs(); got != want {
+				t.Errorf("%s: got timestamp %d, want %d", mf.GetName(), got, want)
+			}
+		}
+	}
+}
+
+func TestStoppedRetention(t *testing.T) {
+	client, server, err := fixtureClient(
+		"testdata/fixtures/fargate_task_metadata.json",
+		"testdata/fixtures/fargate_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	collector, err := NewCollector(client, slog.Default(), Opts{
+		Collectors:       []string{"container", "container_cpu", "container_memory"},
+		StoppedRetention: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.now = server.Now
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	gather := func() map[string]float64 {
+		t.Helper()
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := make(map[string]float64)
+		for _, mf := range families {
+			for _, m := range mf.GetMetric() {
+				if m.GetLabel()[0].GetValue() != "prometheus" {
+					continue
+				}
+				v := m.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_COUNTER {
+					v = m.GetCounter().GetValue()
+				}
+				values[mf.GetName()] = v
+			}
+		}
+		return values
+	}
+
+	running := gather()
+	if running["ecs_container_stopped"] != 0 {
+		t.Errorf("got stopped %v for a running container, want 0", running["ecs_container_stopped"])
+	}
+	if err := server.StopContainer("prometheus", 0); err != nil {
+		t.Fatal(err)
+	}
+	// The retention period runs from the last stats, not from the first
+	// scrape after the container stopped.
+	server.Advance(4 * time.Minute)
+
+	stopped := gather()
+	if got, want := stopped["ecs_container_cpu_usage_seconds_total"], running["ecs_container_cpu_usage_seconds_total"]; got != want {
+		t.Errorf("got CPU usage %v after stopping, want the final %v", got, want)
+	}
+	if stopped["ecs_container_stopped"] != 1 {
+		t.Errorf("got stopped %v for a stopped container, want 1", stopped["ecs_container_stopped"])
+	}
+	if _, ok := stopped["ecs_container_memory_usage_bytes"]; ok {
+		t.Error("expected no memory usage for a stopped container")
+	}
+
+	// Concurrent scrapes, e.g. filtered with collect[], share the history.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			if got := gather()["ecs_container_stopped"]; got != 1 {
+				t.Errorf("got stopped %v in a concurrent scrape, want 1", got)
+			}
+		})
+	}
+	wg.Wait()
+
+	// A Collector replacing this one on reload keeps the history.
+	reloaded, err := NewCollector(client, slog.Default(), Opts{
+		Collectors:       []string{"container", "container_cpu"},
+		StoppedRetention: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.now = server.Now
+	reloaded.KeepHistory(collector)
+	registry.Unregister(collector)
+	registry.MustRegister(reloaded)
+	if got := gather()["ecs_container_cpu_usage_seconds_total"]; got != running["ecs_container_cpu_usage_seconds_total"] {
+		t.Errorf("got CPU usage %v after reloading, want the final %v", got, running["ecs_container_cpu_usage_seconds_total"])
+	}
+
+	server.Advance(2 * time.Minute)
+	if expired := gather(); len(expired) != 0 {
+		t.Errorf("got metrics %v after the retention period, want none", expired)
+	}
+}
+
+func TestStoppedRetentionDisabled(t *testing.T) {
+	client, server, err := fixtureClient(
+		"testdata/fixtures/fargate_task_metadata.json",
+		"testdata/fixtures/fargate_task_stats.json",
 	)
 	if err != nil {
-		t.Fatalf("failed to load test fixtures: %v", err)
+		t.Fatal(err)
+	}
+	defer server.Close()
+	collector, err := NewCollector(client, slog.Default(), Opts{Collectors: []string{"container"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		if mf.GetName() == "ecs_container_stopped" {
+			t.Error("got ecs_container_stopped without a retention period")
+		}
+	}
+}
+
+func TestContainerHistory(t *testing.T) {
+	h := newContainerHistory(time.Minute)
+	now := time.Now()
+	newer := &tmdsv4.StatsResponse{StatsJSON: &container.StatsResponse{Stats: container.Stats{Read: now}}}
+	older := &tmdsv4.StatsResponse{StatsJSON: &container.StatsResponse{Stats: container.Stats{Read: now.Add(-time.Second)}}}
+
+	h.observe([]string{"a", "b"}, map[string]*tmdsv4.StatsResponse{"a": newer, "b": newer}, now)
+	// A scrape which retrieved older stats doesn't replace the newer ones.
+	h.observe([]string{"a", "b"}, map[string]*tmdsv4.StatsResponse{"a": older, "b": older}, now)
+	exposed, stopped := h.observe([]string{"a", "b"}, nil, now.Add(time.Second))
+	if exposed["a"] != newer || !stopped["a"] {
+		t.Errorf("got retained stats %v, stopped %v, want the newer stats", exposed["a"], stopped["a"])
+	}
+
+	// Containers no longer in the task are forgotten.
+	h.observe([]string{"a"}, nil, now.Add(time.Second))
+	if exposed, _ := h.observe([]string{"a", "b"}, nil, now.Add(time.Second)); exposed["b"] != nil {
+		t.Errorf("got retained stats %v for a container which left the task", exposed["b"])
 	}
-	defer metadataServer.Close()
-	collector := NewCollector(metadataClient, slog.Default())
-	assertSnapshot(t, collector, "testdata/snapshots/ec2_metrics.txt")
 }
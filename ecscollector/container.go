@@ -0,0 +1,83 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("container", "Container restarts, stopped state and stats freshness.", true, newContainerCollector)
+}
+
+type containerCollector struct {
+	restartTotalDesc *prometheus.Desc
+	statsAgeDesc     *prometheus.Desc
+	// stoppedDesc is only set if stopped containers are retained.
+	stoppedDesc *prometheus.Desc
+}
+
+func newContainerCollector(b descBuilder) subcollector {
+	c := &containerCollector{
+		restartTotalDesc: b.container(
+			"ecs_container_restarts_total",
+			"Cumulative total count of container restarts. Only has a value if the container has been configured to restart on failure."),
+
+		statsAgeDesc: b.container(
+			"ecs_container_stats_age_seconds",
+			"Time since the ECS agent last read the container's stats. This grows if the agent stops refreshing them."),
+	}
+	if b.retainStopped {
+		c.stoppedDesc = b.container(
+			"ecs_container_stopped",
+			"Whether the container has stopped, in which case its final counters are exposed for the configured grace period.")
+	}
+	return c
+}
+
+func (c *containerCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.restartTotalDesc
+	ch <- c.statsAgeDesc
+	if c.stoppedDesc != nil {
+		ch <- c.stoppedDesc
+	}
+}
+
+func (c *containerCollector) update(ch chan<- prometheus.Metric, s *scrape) {
+	s.eachContainer(func(container containerScrape, labelValues []string) {
+		if container.RestartCount != nil {
+			ch <- s.newCounter(
+				c.restartTotalDesc,
+				float64(*container.RestartCount),
+				container.CreatedAt,
+				labelValues...,
+			)
+		}
+		if c.stoppedDesc != nil {
+			stopped := 0.0
+			if container.stopped {
+				stopped = 1.0
+			}
+			ch <- s.newMetric(c.stoppedDesc, prometheus.GaugeValue, stopped, labelValues...)
+		}
+		if !container.stats.Read.IsZero() {
+			ch <- s.newMetric(
+				c.statsAgeDesc,
+				prometheus.GaugeValue,
+				s.now.Sub(container.stats.Read).Seconds(),
+				labelValues...,
+			)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("container_cpu", "Container CPU usage.", true, newCPUCollector)
+}
+
+type cpuCollector struct {
+	cpuTotalDesc *prometheus.Desc
+}
+
+func newCPUCollector(b descBuilder) subcollector {
+	return &cpuCollector{
+		cpuTotalDesc: b.container(
+			"ecs_container_cpu_usage_seconds_total",
+			"Cumulative total container CPU usage in seconds."),
+	}
+}
+
+func (c *cpuCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cpuTotalDesc
+}
+
+func (c *cpuCollector) update(ch chan<- prometheus.Metric, s *scrape) {
+	s.eachContainer(func(container containerScrape, labelValues []string) {
+		// CPU usage is counted from the container's cgroup, which is created
+		// anew when the container is restarted.
+		ch <- s.statsMetric(container, s.newCounter(
+			c.cpuTotalDesc,
+			float64(container.stats.CPUStats.CPUUsage.TotalUsage)*nanoseconds,
+			container.StartedAt,
+			labelValues...,
+		))
+	})
+}
@@ -0,0 +1,207 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	introspectionv1 "github.com/aws/amazon-ecs-agent/ecs-agent/introspection/v1"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hostTasks is the state of a Collector in daemon mode, in which it monitors
+// every task run by the ECS agent of an EC2 container instance, rather than
+// the task the exporter runs in.
+type hostTasks struct {
+	agent    *ecsmetadata.Client
+	docker   *ecsmetadata.Client
+	metadata *ecsmetadata.Client
+	opts     Opts
+
+	mu    sync.Mutex
+	tasks map[string]*hostTask
+	// kept are the tasks of a previous Collector, by task ARN, until the
+	// agent lists tasks again.
+	kept map[string]*hostTask
+}
+
+// hostTask is a task on the container instance, with the client for its
+// metadata endpoint and the history of its containers.
+type hostTask struct {
+	endpoint string
+	client   *ecsmetadata.Client
+	history  *containerHistory
+	// stoppedAt is when the task was first seen no longer running, or zero.
+	stoppedAt time.Time
+}
+
+// NewDaemonCollector returns a new Collector for every task on the container
+// instance. It lists the running tasks with agent, a client for the ECS
+// agent's introspection API, and looks up the metadata endpoint of each of
+// them in the environment of one of its containers with docker, a client for
+// the Docker Engine API as returned by ecsmetadata.NewDockerClient. The
+// clients for those endpoints are derived from metadata with
+// ecsmetadata.Client.WithEndpoint. The task_arn task label is always added,
+// so that the metrics of different tasks don't collide.
+func NewDaemonCollector(agent, docker, metadata *ecsmetadata.Client, logger *slog.Logger, opts Opts) (*Collector, error) {
+	if opts.ExcludeSelf {
+		return nil, errors.New("excluding the exporter's container is not supported in daemon mode")
+	}
+	if !slices.Contains(opts.TaskLabels, "task_arn") {
+		opts.TaskLabels = slices.Concat(opts.TaskLabels, []string{"task_arn"})
+	}
+	c, err := NewCollector(metadata, logger, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.host = &hostTasks{agent: agent, docker: docker, metadata: metadata, opts: opts}
+	return c, nil
+}
+
+// collect collects the metrics of every running task with a copy of c for
+// each of them, concurrently.
+func (h *hostTasks) collect(ch chan<- prometheus.Metric, c *Collector) {
+	var wg sync.WaitGroup
+	for _, task := range h.running(context.Background(), c.logger, c.now()) {
+		tc := *c
+		tc.client, tc.history, tc.host = task.client, task.history, nil
+		wg.Go(func() { tc.Collect(ch) })
+	}
+	wg.Wait()
+}
+
+// running returns the tasks the agent knows as running, along with those
+// which stopped running less than the stopped container retention period
+// ago, so that the final counters of their containers keep being exposed.
+// It forgets the other tasks. Tasks whose metadata endpoint can't be looked
+// up yet are retried on the next call.
+func (h *hostTasks) running(ctx context.Context, logger *slog.Logger, now time.Time) []*hostTask {
+	resp, err := h.agent.RetrieveAgentTasks(ctx)
+	if err != nil {
+		logger.Debug("Failed to retrieve the agent's tasks", "error", err)
+		return nil
+	}
+	logger.Debug("Got ECS agent tasks response", "tasks", resp)
+
+	// Docker is queried without holding h.mu, so that concurrent scrapes
+	// don't wait on each other's lookups.
+	h.mu.Lock()
+	var added []*introspectionv1.TaskResponse
+	for _, t := range resp.Tasks {
+		if t != nil && t.KnownStatus == "RUNNING" && h.tasks[t.Arn] == nil && h.kept[t.Arn] == nil {
+			added = append(added, t)
+		}
+	}
+	h.mu.Unlock()
+	endpoints := make(map[string]string)
+	for _, t := range added {
+		endpoint, err := h.endpoint(ctx, t)
+		if err != nil {
+			logger.Debug("Skipping task without a metadata endpoint", "arn", t.Arn, "error", err)
+			continue
+		}
+		endpoints[t.Arn] = endpoint
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	tasks := make(map[string]*hostTask)
+	for _, t := range resp.Tasks {
+		if t == nil {
+			continue
+		}
+		task, ok := h.tasks[t.Arn]
+		if !ok {
+			task, ok = h.kept[t.Arn]
+		}
+		if !ok {
+			endpoint, found := endpoints[t.Arn]
+			if !found {
+				continue
+			}
+			task = &hostTask{
+				endpoint: endpoint,
+				client:   h.metadata.WithEndpoint(endpoint),
+				history:  newContainerHistory(h.opts.StoppedRetention),
+			}
+		}
+		if t.KnownStatus == "RUNNING" {
+			task.stoppedAt = time.Time{}
+		} else {
+			if task.stoppedAt.IsZero() {
+				task.stoppedAt = now
+			}
+			if now.Sub(task.stoppedAt) >= h.opts.StoppedRetention {
+				continue
+			}
+		}
+		tasks[t.Arn] = task
+	}
+	h.tasks = tasks
+	h.kept = nil
+
+	running := make([]*hostTask, 0, len(tasks))
+	for _, task := range tasks {
+		running = append(running, task)
+	}
+	return running
+}
+
+// endpoint returns the task metadata endpoint of t, from the environment of
+// the first of its containers that has one.
+func (h *hostTasks) endpoint(ctx context.Context, t *introspectionv1.TaskResponse) (string, error) {
+	var errs []error
+	for _, c := range t.Containers {
+		if c.DockerID == "" {
+			continue
+		}
+		endpoint, err := h.docker.RetrieveMetadataEndpoint(ctx, c.DockerID)
+		if err == nil {
+			return endpoint, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return "", errors.New("no containers")
+	}
+	return "", errors.Join(errs...)
+}
+
+// keepHistory makes h carry on with the tasks of prev, along with the
+// container histories and metadata endpoints of their containers.
+func (h *hostTasks) keepHistory(prev *hostTasks) {
+	prev.mu.Lock()
+	defer prev.mu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.kept = make(map[string]*hostTask)
+	for arn, task := range prev.tasks {
+		if task.history == nil {
+			continue
+		}
+		task.history.setRetention(h.opts.StoppedRetention)
+		h.kept[arn] = &hostTask{
+			endpoint:  task.endpoint,
+			client:    h.metadata.WithEndpoint(task.endpoint),
+			history:   task.history,
+			stoppedAt: task.stoppedAt,
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDaemonCollector(t *testing.T) {
+	var servers []*ecsmetadatatest.Server
+	for _, name := range []string{"fargate", "ec2"} {
+		fixture, err := ecsmetadatatest.LoadFixture(
+			"testdata/fixtures/"+name+"_task_metadata.json",
+			"testdata/fixtures/"+name+"_task_stats.json",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server, err := ecsmetadatatest.NewUnstartedServer(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers = append(servers, server)
+	}
+	agent := ecsmetadatatest.NewAgent(servers...)
+	defer agent.Close()
+
+	collector, err := NewDaemonCollector(agent.Client(), agent.DockerClient(), ecsmetadata.NewClient(""), slog.Default(), Opts{TaskLabels: []string{"cluster_name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	// taskARNs returns the values of the task_arn label of each metric,
+	// by metric name.
+	taskARNs := func() map[string][]string {
+		t.Helper()
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		arns := make(map[string][]string)
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				for _, lp := range m.Label {
+					if lp.GetName() == "task_arn" && !slices.Contains(arns[mf.GetName()], lp.GetValue()) {
+						arns[mf.GetName()] = append(arns[mf.GetName()], lp.GetValue())
+					}
+				}
+			}
+		}
+		for _, values := range arns {
+			slices.Sort(values)
+		}
+		return arns
+	}
+
+	const (
+		ec2ARN     = "arn:aws:ecs:us-east-1:829490980523:task/prom-ecs-exporter-sandbox/506f22fab0414cde856201584703fed9"
+		fargateARN = "arn:aws:ecs:us-east-1:829490980523:task/prom-ecs-exporter-sandbox/bae32def0ab64f06818e8862e58f8d6d"
+	)
+	arns := taskARNs()
+	for _, name := range []string{"ecs_task_metadata_info", "ecs_container_cpu_usage_seconds_total", "ecs_container_memory_usage_bytes"} {
+		if want := []string{ec2ARN, fargateARN}; !slices.Equal(arns[name], want) {
+			t.Errorf("%s: got task ARNs %v, want %v", name, arns[name], want)
+		}
+	}
+
+	agent.SetTasks(servers[1])
+	arns = taskARNs()
+	for _, name := range slices.Sorted(maps.Keys(arns)) {
+		if want := []string{ec2ARN}; !slices.Equal(arns[name], want) {
+			t.Errorf("%s: after the Fargate task stopped, got task ARNs %v, want %v", name, arns[name], want)
+		}
+	}
+	if _, ok := arns["ecs_task_metadata_info"]; !ok {
+		t.Error("ecs_task_metadata_info missing after the Fargate task stopped")
+	}
+
+	if _, err := NewDaemonCollector(agent.Client(), agent.DockerClient(), ecsmetadata.NewClient(""), slog.Default(), Opts{ExcludeSelf: true}); err == nil {
+		t.Error("expected an error excluding the exporter's container in daemon mode")
+	}
+}
+
+func TestDaemonStoppedRetention(t *testing.T) {
+	var servers []*ecsmetadatatest.Server
+	for _, name := range []string{"fargate", "ec2"} {
+		fixture, err := ecsmetadatatest.LoadFixture(
+			"testdata/fixtures/"+name+"_task_metadata.json",
+			"testdata/fixtures/"+name+"_task_stats.json",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server, err := ecsmetadatatest.NewUnstartedServer(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers = append(servers, server)
+	}
+	fargate := servers[0]
+	agent := ecsmetadatatest.NewAgent(servers...)
+	defer agent.Close()
+
+	// The Docker stand-in checks that endpoints are looked up without
+	// holding the lock on the tasks.
+	var collector *Collector
+	var lockedLookups atomic.Int32
+	docker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/containers/") {
+			if collector.host.mu.TryLock() {
+				collector.host.mu.Unlock()
+			} else {
+				lockedLookups.Add(1)
+			}
+		}
+		agent.Handler().ServeHTTP(w, r)
+	}))
+	defer docker.Close()
+
+	newCollector := func(retention time.Duration) (*Collector, prometheus.Gatherer) {
+		t.Helper()
+		c, err := NewDaemonCollector(agent.Client(), ecsmetadata.NewClient(docker.URL), ecsmetadata.NewClient(""), slog.Default(), Opts{
+			Collectors:       []string{"container", "container_cpu", "task"},
+			StoppedRetention: retention,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.now = fargate.Now
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(c)
+		return c, registry
+	}
+	// fargateMetrics returns the names of the metrics of the Fargate task.
+	fargateMetrics := func(g prometheus.Gatherer) []string {
+		t.Helper()
+		mfs, err := g.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				for _, lp := range m.Label {
+					if lp.GetName() == "task_arn" && strings.HasSuffix(lp.GetValue(), "/bae32def0ab64f06818e8862e58f8d6d") && !slices.Contains(names, mf.GetName()) {
+						names = append(names, mf.GetName())
+					}
+				}
+			}
+		}
+		return names
+	}
+
+	collector, retaining := newCollector(5 * time.Minute)
+	_, forgetting := newCollector(0)
+	for _, g := range []prometheus.Gatherer{retaining, forgetting} {
+		if names := fargateMetrics(g); !slices.Contains(names, "ecs_container_cpu_usage_seconds_total") {
+			t.Fatalf("got metrics %v for the running Fargate task, want its CPU usage", names)
+		}
+	}
+
+	var containers []string
+	fargate.Update(func(f *ecsmetadatatest.Fixture) {
+		f.TaskMetadata.KnownStatus = "STOPPED"
+		for _, c := range f.TaskMetadata.Containers {
+			containers = append(containers, c.Name)
+		}
+	})
+	for _, name := range containers {
+		if err := fargate.StopContainer(name, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := fargateMetrics(forgetting); len(names) != 0 {
+		t.Errorf("got metrics %v for the stopped Fargate task without retention, want none", names)
+	}
+	if names := fargateMetrics(retaining); !slices.Contains(names, "ecs_container_stopped") || !slices.Contains(names, "ecs_container_cpu_usage_seconds_total") {
+		t.Errorf("got metrics %v for the stopped Fargate task, want its final CPU usage", names)
+	}
+
+	// The retention period of the task runs from the first scrape which
+	// found it stopped, and carries over to a reloaded Collector.
+	fargate.Advance(4 * time.Minute)
+	reloaded, registry := newCollector(5 * time.Minute)
+	reloaded.KeepHistory(collector)
+	collector = reloaded
+	if names := fargateMetrics(registry); !slices.Contains(names, "ecs_task_metadata_info") {
+		t.Errorf("got metrics %v for the stopped Fargate task after reload, want its task metadata", names)
+	}
+	fargate.Advance(2 * time.Minute)
+	if names := fargateMetrics(registry); len(names) != 0 {
+		t.Errorf("got metrics %v for the Fargate task after the retention period, want none", names)
+	}
+	if n := lockedLookups.Load(); n != 0 {
+		t.Errorf("%d endpoint lookups held the lock on the tasks", n)
+	}
+}
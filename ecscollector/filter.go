@@ -0,0 +1,90 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"sync"
+
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// containerFilter selects the containers to produce metrics for.
+type containerFilter struct {
+	include      *regexp.Regexp
+	exclude      *regexp.Regexp
+	includeTypes []string
+	excludeTypes []string
+	// self is nil unless the exporter's own container is excluded.
+	self *selfContainer
+}
+
+func newContainerFilter(opts Opts) containerFilter {
+	f := containerFilter{
+		include:      opts.IncludeContainers,
+		exclude:      opts.ExcludeContainers,
+		includeTypes: opts.IncludeContainerTypes,
+		excludeTypes: opts.ExcludeContainerTypes,
+	}
+	if opts.ExcludeSelf {
+		f.self = &selfContainer{}
+	}
+	return f
+}
+
+// match reports whether metrics should be produced for container c. selfID is
+// the ID of the exporter's own container, if known.
+func (f containerFilter) match(c tmdsv4.ContainerResponse, selfID string) bool {
+	if f.include != nil && !f.include.MatchString(c.Name) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(c.Name) {
+		return false
+	}
+	if f.includeTypes != nil && !slices.Contains(f.includeTypes, c.Type) {
+		return false
+	}
+	if slices.Contains(f.excludeTypes, c.Type) {
+		return false
+	}
+	return selfID == "" || c.ID != selfID
+}
+
+// selfContainer is the ID of the container the exporter runs in, retrieved
+// from the container metadata endpoint the first time it is needed. It is
+// shared by filtered copies of a Collector.
+type selfContainer struct {
+	mu sync.Mutex
+	id string
+}
+
+// get returns the ID of the exporter's container. It is retried on every
+// scrape until it succeeds, so that a transient error doesn't keep the
+// exporter's container in the metrics for good.
+func (s *selfContainer) get(ctx context.Context, client *ecsmetadata.Client) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.id != "" {
+		return s.id, nil
+	}
+	container, err := client.RetrieveContainerMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.id = container.ID
+	return s.id, nil
+}
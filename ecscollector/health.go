@@ -0,0 +1,56 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("health", "Container health check status.", true, newHealthCollector)
+}
+
+// healthStatuses are the container health statuses reported by the ECS agent.
+var healthStatuses = []string{"HEALTHY", "UNHEALTHY", "UNKNOWN"}
+
+type healthCollector struct {
+	healthStatusDesc *prometheus.Desc
+}
+
+func newHealthCollector(b descBuilder) subcollector {
+	return &healthCollector{
+		healthStatusDesc: b.container(
+			"ecs_container_health_status",
+			"Current container health check status, 1 for the status the container is in and 0 for the others. Only has a value if the container has a health check configured.", "status"),
+	}
+}
+
+func (c *healthCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.healthStatusDesc
+}
+
+func (c *healthCollector) update(ch chan<- prometheus.Metric, s *scrape) {
+	s.eachContainer(func(container containerScrape, labelValues []string) {
+		if container.Health == nil {
+			return
+		}
+		for _, status := range healthStatuses {
+			value := 0.0
+			if container.Health.Status == status {
+				value = 1.0
+			}
+			ch <- s.newMetric(c.healthStatusDesc, prometheus.GaugeValue, value, append(labelValues, status)...)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// taskLabelValues are the task-level labels which can be added to every
+// metric, with the function extracting each from the task metadata.
+var taskLabelValues = map[string]func(t *tmdsv4.TaskResponse) string{
+	"cluster":           func(t *tmdsv4.TaskResponse) string { return t.Cluster },
+	"cluster_name":      func(t *tmdsv4.TaskResponse) string { return arnResource(t.Cluster, "cluster/") },
+	"task_arn":          func(t *tmdsv4.TaskResponse) string { return t.TaskARN },
+	"task_id":           func(t *tmdsv4.TaskResponse) string { return t.TaskARN[strings.LastIndex(t.TaskARN, "/")+1:] },
+	"family":            func(t *tmdsv4.TaskResponse) string { return t.Family },
+	"revision":          func(t *tmdsv4.TaskResponse) string { return t.Revision },
+	"service_name":      func(t *tmdsv4.TaskResponse) string { return t.ServiceName },
+	"availability_zone": func(t *tmdsv4.TaskResponse) string { return t.AvailabilityZone },
+	"launch_type":       func(t *tmdsv4.TaskResponse) string { return t.LaunchType },
+	"region":            func(t *tmdsv4.TaskResponse) string { return arnField(t.TaskARN, 3) },
+	"account_id":        func(t *tmdsv4.TaskResponse) string { return arnField(t.TaskARN, 4) },
+}
+
+// TaskLabels returns the names of the task-level labels which can be added to
+// every metric with Opts.TaskLabels, sorted by name.
+func TaskLabels() []string {
+	names := make([]string, 0, len(taskLabelValues))
+	for name := range taskLabelValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TaskLabelValue returns the value of the task-level label name for task, as
+// added to metrics with Opts.TaskLabels, or "" if there is no such label.
+func TaskLabelValue(task *tmdsv4.TaskResponse, name string) string {
+	if value, ok := taskLabelValues[name]; ok {
+		return value(task)
+	}
+	return ""
+}
+
+func validateTaskLabels(names []string) error {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := taskLabelValues[name]; !ok {
+			return fmt.Errorf("unknown task label %q, must be one of %s", name, strings.Join(TaskLabels(), ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate task label %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// arnField returns the field of an ARN at index i, e.g. the region at 3 and
+// the account ID at 4, or "" if arn is not an ARN.
+func arnField(arn string, i int) string {
+	fields := strings.SplitN(arn, ":", 6)
+	if len(fields) != 6 || fields[0] != "arn" {
+		return ""
+	}
+	return fields[i]
+}
+
+// arnResource strips the resource type prefix from the resource of an ARN.
+// Anything else, like a bare cluster name, is returned as is.
+func arnResource(arn, prefix string) string {
+	if resource := arnField(arn, 5); resource != "" {
+		return strings.TrimPrefix(resource, prefix)
+	}
+	return arn
+}
+
+// ContainerLabel maps a Docker label of containers to a label of
+// container-level metrics.
+type ContainerLabel struct {
+	// DockerLabel is the name of the Docker label, e.g. "app.version".
+	DockerLabel string
+	// Name is the name of the metric label. If empty, it is the Docker label
+	// name sanitized and prefixed with "container_label_", like in cAdvisor,
+	// e.g. "container_label_app_version".
+	Name string
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ParseContainerLabel parses a container label mapping in the form
+// "<docker label>[=<name>]".
+func ParseContainerLabel(s string) (ContainerLabel, error) {
+	dockerLabel, name, _ := strings.Cut(s, "=")
+	if dockerLabel == "" {
+		return ContainerLabel{}, fmt.Errorf("invalid container label %q: missing Docker label", s)
+	}
+	return ContainerLabel{DockerLabel: dockerLabel, Name: name}, nil
+}
+
+func (l ContainerLabel) labelName() string {
+	if l.Name != "" {
+		return l.Name
+	}
+	return "container_label_" + invalidLabelChars.ReplaceAllString(l.DockerLabel, "_")
+}
+
+// containerLabelNames returns the metric label names of labels, failing if
+// any is invalid or conflicts with another label.
+func containerLabelNames(labels []ContainerLabel, taskLabels []string) ([]string, error) {
+	reserved := map[string]string{"status": "the health status label"}
+	for _, name := range slices.Concat(containerLabels, networkLabels) {
+		reserved[name] = "a built-in label"
+	}
+	for _, name := range taskLabels {
+		reserved[name] = "a task label"
+	}
+	var names []string
+	for _, l := range labels {
+		name := l.labelName()
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid label name %q for Docker label %q", name, l.DockerLabel)
+		}
+		if conflict, ok := reserved[name]; ok {
+			return nil, fmt.Errorf("label name %q for Docker label %q conflicts with %s", name, l.DockerLabel, conflict)
+		}
+		reserved[name] = fmt.Sprintf("the label for Docker label %q", l.DockerLabel)
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// descBuilder creates the descriptors of a Collector's metrics, which carry
+// the configured task labels after their own labels.
+type descBuilder struct {
+	// containerLabels are the names of the labels mapped from Docker labels,
+	// following container_name on container-level metrics.
+	containerLabels []string
+	taskLabels      []string
+	// retainStopped is set if the final counters of stopped containers are
+	// retained, see Opts.StoppedRetention.
+	retainStopped bool
+}
+
+// task returns the descriptor of a task-level metric.
+func (b descBuilder) task(name, help string) *prometheus.Desc {
+	return b.newDesc(name, help, taskLabels)
+}
+
+// container returns the descriptor of a container-level metric, with the
+// given labels following the container labels.
+func (b descBuilder) container(name, help string, labels ...string) *prometheus.Desc {
+	return b.newDesc(name, help, slices.Concat(containerLabels, b.containerLabels, labels))
+}
+
+// network returns the descriptor of a network-level metric.
+func (b descBuilder) network(name, help string) *prometheus.Desc {
+	return b.newDesc(name, help, networkLabels)
+}
+
+func (b descBuilder) newDesc(name, help string, labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(name, help, slices.Concat(labels, b.taskLabels), nil)
+}
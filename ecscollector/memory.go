@@ -0,0 +1,102 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("container_memory", "Container memory usage, page cache and limits.", true, newMemoryCollector)
+}
+
+type memoryCollector struct {
+	memUsageDesc     *prometheus.Desc
+	memLimitDesc     *prometheus.Desc
+	memCacheSizeDesc *prometheus.Desc
+}
+
+func newMemoryCollector(b descBuilder) subcollector {
+	return &memoryCollector{
+		memUsageDesc: b.container(
+			"ecs_container_memory_usage_bytes",
+			"Current container memory usage in bytes."),
+
+		memLimitDesc: b.container(
+			"ecs_container_memory_limit_bytes",
+			"Configured container memory limit in bytes, set from the container-level limit in the task definition if any, otherwise the task-level limit."),
+
+		memCacheSizeDesc: b.container(
+			"ecs_container_memory_page_cache_size_bytes",
+			"Current container memory page cache size in bytes. This is not a subset of used bytes."),
+	}
+}
+
+func (c *memoryCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.memUsageDesc
+	ch <- c.memLimitDesc
+	ch <- c.memCacheSizeDesc
+}
+
+func (c *memoryCollector) update(ch chan<- prometheus.Metric, s *scrape) {
+	s.eachContainer(func(container containerScrape, labelValues []string) {
+		// The last memory usage of a stopped container is no longer current.
+		if container.stopped {
+			return
+		}
+		stats := container.stats
+
+		cacheValue := 0.0
+		if val, ok := stats.MemoryStats.Stats["cache"]; ok {
+			cacheValue = float64(val)
+		}
+
+		for desc, value := range map[*prometheus.Desc]float64{
+			c.memUsageDesc:     float64(stats.MemoryStats.Usage),
+			c.memCacheSizeDesc: cacheValue,
+		} {
+			ch <- s.statsMetric(container, s.newMetric(
+				desc,
+				prometheus.GaugeValue,
+				value,
+				labelValues...,
+			))
+		}
+		if limit, ok := containerMemoryLimit(s.metadata, container.ContainerResponse); ok {
+			ch <- s.newMetric(
+				c.memLimitDesc,
+				prometheus.GaugeValue,
+				float64(limit*mebibytes),
+				labelValues...,
+			)
+		} else {
+			s.logger.Debug("Couldn't find memory limit for container", "id", container.ID)
+		}
+	})
+}
+
+// containerMemoryLimit returns the container's memory limit in MiB, which is
+// its own, if any, otherwise the task's limit. This is correct in that this is
+// the precise logic used to configure the cgroups limit for the container.
+// ECS requires one of them to be set, but we don't rely on it.
+func containerMemoryLimit(metadata *tmdsv4.TaskResponse, container tmdsv4.ContainerResponse) (int64, bool) {
+	if container.Limits.Memory != nil {
+		return *container.Limits.Memory, true
+	}
+	if metadata.Limits != nil && metadata.Limits.Memory != nil {
+		return *metadata.Limits.Memory, true
+	}
+	return 0, false
+}
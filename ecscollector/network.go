@@ -0,0 +1,140 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"github.com/docker/docker/api/types/container"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("network", "Network traffic, drops and errors per interface.", true, newNetworkCollector)
+}
+
+type networkCollector struct {
+	rxBytesDesc   *prometheus.Desc
+	rxPacketsDesc *prometheus.Desc
+	rxDroppedDesc *prometheus.Desc
+	rxErrorsDesc  *prometheus.Desc
+	txBytesDesc   *prometheus.Desc
+	txPacketsDesc *prometheus.Desc
+	txDroppedDesc *prometheus.Desc
+	txErrorsDesc  *prometheus.Desc
+}
+
+func newNetworkCollector(b descBuilder) subcollector {
+	return &networkCollector{
+		rxBytesDesc: b.network(
+			"ecs_network_receive_bytes_total",
+			"Cumulative total size of network packets received in bytes."),
+
+		rxPacketsDesc: b.network(
+			"ecs_network_receive_packets_total",
+			"Cumulative total count of network packets received."),
+
+		rxDroppedDesc: b.network(
+			"ecs_network_receive_packets_dropped_total",
+			"Cumulative total count of network packets dropped in receiving."),
+
+		rxErrorsDesc: b.network(
+			"ecs_network_receive_errors_total",
+			"Cumulative total count of network errors in receiving."),
+
+		txBytesDesc: b.network(
+			"ecs_network_transmit_bytes_total",
+			"Cumulative total size of network packets transmitted in bytes."),
+
+		txPacketsDesc: b.network(
+			"ecs_network_transmit_packets_total",
+			"Cumulative total count of network packets transmitted."),
+
+		txDroppedDesc: b.network(
+			"ecs_network_transmit_packets_dropped_total",
+			"Cumulative total count of network packets dropped in transmit."),
+
+		txErrorsDesc: b.network(
+			"ecs_network_transmit_errors_total",
+			"Cumulative total count of network errors in transmit."),
+	}
+}
+
+func (c *networkCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.rxBytesDesc
+	ch <- c.rxPacketsDesc
+	ch <- c.rxDroppedDesc
+	ch <- c.rxErrorsDesc
+	ch <- c.txBytesDesc
+	ch <- c.txPacketsDesc
+	ch <- c.txDroppedDesc
+	ch <- c.txErrorsDesc
+}
+
+func (c *networkCollector) update(ch chan<- prometheus.Metric, s *scrape) {
+	networks := make(map[string]container.NetworkStats)
+	stoppedNetworks := make(map[string]container.NetworkStats)
+	for _, container := range s.runningContainers() {
+		if container.stopped {
+			// The interfaces of a stopped container may still be used by
+			// running containers, whose stats are up to date.
+			for iface, netStats := range container.stats.Networks {
+				stoppedNetworks[iface] = netStats
+			}
+			continue
+		}
+		for iface, netStats := range container.stats.Networks {
+			// While the API response attaches network stats to each container,
+			// the container is in fact not a relevant dimension; only the
+			// interface is. This means that if multiple containers use the same
+			// network (extremely likely), we are redundantly writing this
+			// metric with "last one wins" semantics. This is fine: the values
+			// for an interface are the same across all containers.
+			//
+			// The collection process will error if you report the same metric
+			// multiple times, however, so we have to stash this data in the
+			// `networks` map to ensure that we only send one metric per
+			// interface.
+			networks[iface] = netStats
+		}
+	}
+
+	for iface, netStats := range stoppedNetworks {
+		if _, ok := networks[iface]; !ok {
+			networks[iface] = netStats
+		}
+	}
+
+	for iface, netStats := range networks {
+		networkLabelVals := []string{
+			iface,
+		}
+
+		for desc, value := range map[*prometheus.Desc]float64{
+			c.rxBytesDesc:   float64(netStats.RxBytes),
+			c.rxPacketsDesc: float64(netStats.RxPackets),
+			c.rxDroppedDesc: float64(netStats.RxDropped),
+			c.rxErrorsDesc:  float64(netStats.RxErrors),
+			c.txBytesDesc:   float64(netStats.TxBytes),
+			c.txPacketsDesc: float64(netStats.TxPackets),
+			c.txDroppedDesc: float64(netStats.TxDropped),
+			c.txErrorsDesc:  float64(netStats.TxErrors),
+		} {
+			ch <- s.newMetric(
+				desc,
+				prometheus.CounterValue,
+				value,
+				networkLabelVals...,
+			)
+		}
+	}
+}
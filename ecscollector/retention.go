@@ -0,0 +1,99 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+)
+
+// containerHistory remembers the last stats of each container, so that the
+// final counters of a stopped container, for which the metadata server
+// returns empty stats, keep being exposed for a grace period. Otherwise
+// rate() would never see their last increment. It is shared by filtered
+// copies of a Collector, and by concurrent scrapes, and is carried over to
+// the Collector replacing it on reload.
+type containerHistory struct {
+	mu         sync.Mutex
+	retention  time.Duration
+	containers map[string]*containerRecord
+}
+
+type containerRecord struct {
+	stats *tmdsv4.StatsResponse
+	// read is when stats were read by the ECS agent, which the retention
+	// period runs from.
+	read time.Time
+}
+
+func newContainerHistory(retention time.Duration) *containerHistory {
+	if retention <= 0 {
+		return nil
+	}
+	return &containerHistory{retention: retention, containers: make(map[string]*containerRecord)}
+}
+
+// setRetention changes the retention period of h.
+func (h *containerHistory) setRetention(retention time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.retention = retention
+}
+
+// observe records the stats of a task's containers by ID as of now, in which
+// those of stopped containers are nil or empty, and forgets the containers
+// which are no longer in the task, whose IDs are ids. It returns the stats to
+// expose for each container, and the IDs of the stopped containers whose
+// retained stats they are. A nil history retains nothing.
+//
+// Stats older than those recorded, e.g. from a concurrent scrape which
+// retrieved them earlier, don't replace them.
+func (h *containerHistory) observe(ids []string, stats map[string]*tmdsv4.StatsResponse, now time.Time) (map[string]*tmdsv4.StatsResponse, map[string]bool) {
+	if h == nil {
+		return stats, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	exposed := make(map[string]*tmdsv4.StatsResponse, len(ids))
+	stopped := make(map[string]bool)
+	for _, id := range ids {
+		s := stats[id]
+		record, ok := h.containers[id]
+		if s != nil && s.StatsJSON != nil {
+			read := s.Read
+			if read.IsZero() {
+				read = now
+			}
+			if !ok || !read.Before(record.read) {
+				h.containers[id] = &containerRecord{stats: s, read: read}
+			}
+			exposed[id] = s
+			continue
+		}
+		if ok && now.Sub(record.read) < h.retention {
+			exposed[id], stopped[id] = record.stats, true
+			continue
+		}
+		exposed[id] = s
+	}
+	for id := range h.containers {
+		if !slices.Contains(ids, id) {
+			delete(h.containers, id)
+		}
+	}
+	return exposed, stopped
+}
@@ -0,0 +1,171 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecscollector
+
+import (
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("task", "Task metadata, limits, ephemeral storage and image pull times.", true, newTaskCollector)
+}
+
+var taskMetadataLabels = []string{
+	"cluster",
+	"task_arn",
+	"family",
+	"revision",
+	"desired_status",
+	"known_status",
+	"availability_zone",
+	"launch_type",
+}
+
+type taskCollector struct {
+	// metadataExtraLabels are the task labels added to ecs_task_metadata_info
+	// which it doesn't already have.
+	metadataExtraLabels []string
+
+	metadataDesc                  *prometheus.Desc
+	cpuLimitDesc                  *prometheus.Desc
+	memLimitDesc                  *prometheus.Desc
+	ephemeralStorageUsedDesc      *prometheus.Desc
+	ephemeralStorageAllocatedDesc *prometheus.Desc
+	imagePullStartDesc            *prometheus.Desc
+	imagePullStopDesc             *prometheus.Desc
+}
+
+func newTaskCollector(b descBuilder) subcollector {
+	var extraLabels []string
+	for _, name := range b.taskLabels {
+		if !slices.Contains(taskMetadataLabels, name) {
+			extraLabels = append(extraLabels, name)
+		}
+	}
+	return &taskCollector{
+		metadataExtraLabels: extraLabels,
+
+		metadataDesc: prometheus.NewDesc(
+			"ecs_task_metadata_info",
+			"ECS task metadata, sourced from the task metadata endpoint version 4.",
+			slices.Concat(taskMetadataLabels, extraLabels), nil),
+
+		cpuLimitDesc: b.task(
+			"ecs_task_cpu_limit_vcpus",
+			"Configured task CPU limit in vCPUs (1 vCPU = 1024 CPU units). This is optional when running on EC2; if no limit is set, this metric has no value."),
+
+		memLimitDesc: b.task(
+			"ecs_task_memory_limit_bytes",
+			"Configured task memory limit in bytes. This is optional when running on EC2; if no limit is set, this metric has no value."),
+
+		ephemeralStorageUsedDesc: b.task(
+			"ecs_task_ephemeral_storage_used_bytes",
+			"Current Fargate task ephemeral storage usage in bytes."),
+
+		ephemeralStorageAllocatedDesc: b.task(
+			"ecs_task_ephemeral_storage_allocated_bytes",
+			"Configured Fargate task ephemeral storage allocated size in bytes."),
+
+		imagePullStartDesc: b.task(
+			"ecs_task_image_pull_start_timestamp_seconds",
+			"The time at which the task started pulling docker images for its containers."),
+
+		imagePullStopDesc: b.task(
+			"ecs_task_image_pull_stop_timestamp_seconds",
+			"The time at which the task stopped (i.e. completed) pulling docker images for its containers."),
+	}
+}
+
+func (c *taskCollector) describe(ch chan<- *prometheus.Desc) {
+	ch <- c.metadataDesc
+	ch <- c.cpuLimitDesc
+	ch <- c.memLimitDesc
+	ch <- c.ephemeralStorageUsedDesc
+	ch <- c.ephemeralStorageAllocatedDesc
+	ch <- c.imagePullStartDesc
+	ch <- c.imagePullStopDesc
+}
+
+func (c *taskCollector) update(ch chan<- prometheus.Metric, s *scrape) {
+	metadata := s.metadata
+
+	metadataLabelValues := []string{
+		metadata.Cluster,
+		metadata.TaskARN,
+		metadata.Family,
+		metadata.Revision,
+		metadata.DesiredStatus,
+		metadata.KnownStatus,
+		metadata.AvailabilityZone,
+		metadata.LaunchType,
+	}
+	for _, name := range c.metadataExtraLabels {
+		metadataLabelValues = append(metadataLabelValues, taskLabelValues[name](metadata))
+	}
+	ch <- newMetric(
+		c.metadataDesc,
+		prometheus.GaugeValue,
+		1.0,
+		metadataLabelValues...,
+	)
+
+	// Task CPU/memory limits are optional when running on EC2 - the relevant
+	// limits may only exist at the container level.
+	if metadata.Limits != nil {
+		if metadata.Limits.CPU != nil {
+			ch <- s.newMetric(
+				c.cpuLimitDesc,
+				prometheus.GaugeValue,
+				*metadata.Limits.CPU,
+			)
+		}
+		if metadata.Limits.Memory != nil {
+			ch <- s.newMetric(
+				c.memLimitDesc,
+				prometheus.GaugeValue,
+				float64(*metadata.Limits.Memory*mebibytes),
+			)
+		}
+	}
+
+	if metadata.EphemeralStorageMetrics != nil {
+		ch <- s.newMetric(
+			c.ephemeralStorageUsedDesc,
+			prometheus.GaugeValue,
+			float64(metadata.EphemeralStorageMetrics.UtilizedMiBs*mebibytes),
+		)
+		ch <- s.newMetric(
+			c.ephemeralStorageAllocatedDesc,
+			prometheus.GaugeValue,
+			float64(metadata.EphemeralStorageMetrics.ReservedMiBs*mebibytes),
+		)
+	}
+
+	if metadata.PullStartedAt != nil {
+		ch <- s.newMetric(
+			c.imagePullStartDesc,
+			prometheus.GaugeValue,
+			float64(metadata.PullStartedAt.UnixNano())*nanoseconds,
+		)
+	}
+	if metadata.PullStoppedAt != nil {
+		ch <- s.newMetric(
+			c.imagePullStopDesc,
+			prometheus.GaugeValue,
+			float64(metadata.PullStoppedAt.UnixNano())*nanoseconds,
+		)
+	}
+}
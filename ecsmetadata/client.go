@@ -23,16 +23,37 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
+	"sync"
+	"time"
 
+	introspectionv1 "github.com/aws/amazon-ecs-agent/ecs-agent/introspection/v1"
 	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
 )
 
+// endpointEnv is the environment variable the ECS agent sets to the task
+// metadata endpoint of each container.
+const endpointEnv = "ECS_CONTAINER_METADATA_URI_V4"
+
 type Client struct {
 	// HTTClient is the client to use when making HTTP requests when set.
 	HTTPClient *http.Client
 
+	// SchemaMonitor, when set, checks every decoded response for schema
+	// drift.
+	SchemaMonitor *SchemaMonitor
+
 	// metadata server endpoint
 	endpoint string
+
+	cacheMu sync.Mutex
+	cache   map[string]*cachedResponse
+}
+
+type cachedResponse struct {
+	ttl     time.Duration
+	body    []byte
+	expires time.Time
 }
 
 // NewClient returns a new Client. endpoint is the metadata server endpoint.
@@ -46,7 +67,6 @@ func NewClient(endpoint string) *Client {
 // NewClientFromEnvironment is like NewClient but endpoint
 // is discovered from the environment.
 func NewClientFromEnvironment() (*Client, error) {
-	const endpointEnv = "ECS_CONTAINER_METADATA_URI_V4"
 	endpoint := os.Getenv(endpointEnv)
 	if endpoint == "" {
 		return nil, fmt.Errorf("%s is not set; not running on ECS?", endpointEnv)
@@ -61,7 +81,7 @@ func NewClientFromEnvironment() (*Client, error) {
 func (c *Client) RetrieveTaskStats(ctx context.Context) (map[string]*tmdsv4.StatsResponse, error) {
 	// https://github.com/aws/amazon-ecs-agent/blob/cf8c7a6b65043c550533f330b10aef6d0a342214/agent/handlers/v4/tmdsstate.go#L202
 	out := make(map[string]*tmdsv4.StatsResponse)
-	err := c.request(ctx, c.endpoint+"/task/stats", &out)
+	err := c.request(ctx, "/task/stats", &out)
 	return out, err
 }
 
@@ -78,30 +98,131 @@ func (c *Client) RetrieveTaskMetadata(ctx context.Context) (*tmdsv4.TaskResponse
 	// But `TaskResponse` is the _union_ of these two responses. It has all the
 	// fields.
 	var out tmdsv4.TaskResponse
-	err := c.request(ctx, c.endpoint+"/task", &out)
+	err := c.request(ctx, "/task", &out)
 	return &out, err
 }
 
-func (c *Client) request(ctx context.Context, uri string, out interface{}) error {
-	req, err := http.NewRequest("GET", uri, nil)
+// RetrieveContainerMetadata returns the metadata of the container the client
+// runs in, which the endpoint is scoped to.
+func (c *Client) RetrieveContainerMetadata(ctx context.Context) (*tmdsv4.ContainerResponse, error) {
+	// https://github.com/aws/amazon-ecs-agent/blob/cf8c7a6b65043c550533f330b10aef6d0a342214/agent/handlers/v4/tmdsstate.go#L139
+	var out tmdsv4.ContainerResponse
+	err := c.request(ctx, "", &out)
+	return &out, err
+}
+
+// RetrieveAgentTasks returns the tasks managed by the ECS agent of an EC2
+// container instance, from its introspection API, which the client's
+// endpoint must be, e.g. http://localhost:51678.
+func (c *Client) RetrieveAgentTasks(ctx context.Context) (*introspectionv1.TasksResponse, error) {
+	var out introspectionv1.TasksResponse
+	err := c.request(ctx, "/v1/tasks", &out)
+	return &out, err
+}
+
+// WithEndpoint returns a Client for another metadata server endpoint, e.g.
+// that of another task. It shares the client's HTTP client and schema
+// monitor, and starts with its cache TTLs.
+func (c *Client) WithEndpoint(endpoint string) *Client {
+	other := NewClient(endpoint)
+	other.HTTPClient = c.HTTPClient
+	other.SchemaMonitor = c.SchemaMonitor
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	for path, cached := range c.cache {
+		other.SetCacheTTL(path, cached.ttl)
+	}
+	return other
+}
+
+// RetrieveRaw returns the unparsed response body of the metadata server for
+// path, which is relative to the endpoint, e.g. "/task/stats".
+func (c *Client) RetrieveRaw(ctx context.Context, path string) ([]byte, error) {
+	return c.get(ctx, c.endpoint+path)
+}
+
+// SetCacheTTL makes the client reuse the response for path, e.g. "/task", for
+// ttl after retrieving it, instead of querying the metadata server every time.
+// A zero ttl disables caching, which is the default. It is safe to call while
+// the client is in use.
+func (c *Client) SetCacheTTL(path string, ttl time.Duration) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if ttl <= 0 {
+		delete(c.cache, path)
+		return
+	}
+	if c.cache == nil {
+		c.cache = make(map[string]*cachedResponse)
+	}
+	if cached, ok := c.cache[path]; ok {
+		cached.expires = cached.expires.Add(ttl - cached.ttl)
+		cached.ttl = ttl
+		return
+	}
+	c.cache[path] = &cachedResponse{ttl: ttl}
+}
+
+// cached returns the cached response body for path, if any and fresh.
+func (c *Client) cached(path string) []byte {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if cached, ok := c.cache[path]; ok && time.Now().Before(cached.expires) {
+		return cached.body
+	}
+	return nil
+}
+
+func (c *Client) store(path string, body []byte) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if cached, ok := c.cache[path]; ok {
+		cached.body = body
+		cached.expires = time.Now().Add(cached.ttl)
+	}
+}
+
+func (c *Client) request(ctx context.Context, path string, out interface{}) error {
+	if body := c.cached(path); body != nil {
+		return json.Unmarshal(body, out)
+	}
+	body, err := c.get(ctx, c.endpoint+path)
 	if err != nil {
 		return err
 	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return err
+	}
+	c.store(path, body)
+	if c.SchemaMonitor != nil {
+		endpoint := path
+		if endpoint == "" {
+			endpoint = "/"
+		}
+		c.SchemaMonitor.check(endpoint, body, reflect.TypeOf(out))
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
 	req = req.WithContext(ctx)
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("%q: %s %s: %q", uri, resp.Proto, resp.Status, string(body))
+		return nil, fmt.Errorf("%q: %s %s: %q", uri, resp.Proto, resp.Status, string(body))
 	}
-
-	return json.Unmarshal(body, out)
+	return body, nil
 }
@@ -0,0 +1,70 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"Cluster": "test"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	retrieve := func() {
+		t.Helper()
+		task, err := client.RetrieveTaskMetadata(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.Cluster != "test" {
+			t.Fatalf("got cluster %q, want %q", task.Cluster, "test")
+		}
+	}
+
+	retrieve()
+	retrieve()
+	if requests != 2 {
+		t.Errorf("got %d requests without caching, want 2", requests)
+	}
+
+	client.SetCacheTTL("/task", time.Hour)
+	retrieve()
+	retrieve()
+	if requests != 3 {
+		t.Errorf("got %d requests with caching, want 3", requests)
+	}
+
+	// Shortening the TTL applies to the cached response.
+	client.SetCacheTTL("/task", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	retrieve()
+	if requests != 4 {
+		t.Errorf("got %d requests after the TTL, want 4", requests)
+	}
+
+	client.SetCacheTTL("/task", 0)
+	retrieve()
+	if requests != 5 {
+		t.Errorf("got %d requests after disabling caching, want 5", requests)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadata
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewDockerClient returns a new Client for the Docker Engine API at host,
+// either a unix:// socket path, e.g. unix:///var/run/docker.sock, or an
+// http:// URL. It is meant for RetrieveMetadataEndpoint only; don't set its
+// SchemaMonitor, whose endpoint label would be the container IDs.
+func NewDockerClient(host string) (*Client, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse Docker host %q: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		var dialer net.Dialer
+		c := NewClient("http://docker")
+		c.HTTPClient = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", u.Path)
+			},
+		}}
+		return c, nil
+	case "http", "https":
+		return NewClient(strings.TrimSuffix(host, "/")), nil
+	default:
+		return nil, fmt.Errorf("unsupported Docker host %q", host)
+	}
+}
+
+// RetrieveMetadataEndpoint returns the task metadata endpoint the ECS agent
+// gave the container with the given ID, from its environment as inspected
+// with the Docker Engine API, which the client's endpoint must be. The agent
+// keys the endpoints by their own IDs, not by container IDs.
+func (c *Client) RetrieveMetadataEndpoint(ctx context.Context, id string) (string, error) {
+	var out struct {
+		Config struct {
+			Env []string
+		}
+	}
+	if err := c.request(ctx, "/containers/"+url.PathEscape(id)+"/json", &out); err != nil {
+		return "", err
+	}
+	for _, env := range out.Config.Env {
+		if endpoint, ok := strings.CutPrefix(env, endpointEnv+"="); ok {
+			return endpoint, nil
+		}
+	}
+	return "", fmt.Errorf("container %s has no %s", id, endpointEnv)
+}
@@ -0,0 +1,71 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadata
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRetrieveMetadataEndpoint(t *testing.T) {
+	// t.TempDir may exceed the length limit of unix socket paths.
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/with-endpoint/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Config": {"Env": ["PATH=/bin", "ECS_CONTAINER_METADATA_URI_V4=http://169.254.170.2/v4/0123-abcd"]}}`))
+	})
+	mux.HandleFunc("GET /containers/without-endpoint/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Config": {"Env": ["PATH=/bin"]}}`))
+	})
+	server := httptest.NewUnstartedServer(mux)
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	client, err := NewDockerClient("unix://" + socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint, err := client.RetrieveMetadataEndpoint(context.Background(), "with-endpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://169.254.170.2/v4/0123-abcd"; endpoint != want {
+		t.Errorf("got endpoint %q, want %q", endpoint, want)
+	}
+	if _, err := client.RetrieveMetadataEndpoint(context.Background(), "without-endpoint"); err == nil {
+		t.Error("expected an error for a container without a metadata endpoint")
+	}
+	if _, err := client.RetrieveMetadataEndpoint(context.Background(), "missing"); err == nil {
+		t.Error("expected an error for a missing container")
+	}
+
+	if _, err := NewDockerClient("tcp://localhost:2375"); err == nil {
+		t.Error("expected an error for an unsupported Docker host")
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadatatest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+
+	introspectionv1 "github.com/aws/amazon-ecs-agent/ecs-agent/introspection/v1"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// Agent is a fake ECS agent of an EC2 container instance, running the tasks
+// of a set of Servers. It serves the introspection API's /v1/tasks, and the
+// task metadata endpoint of each task under /v4/<endpoint ID> for each of its
+// containers. Like the real agent, it keys the endpoints by IDs of their own,
+// not by container IDs; it also stands in for the Docker Engine API, whose
+// /containers/<container ID>/json gives the endpoint in the container's
+// ECS_CONTAINER_METADATA_URI_V4 environment variable.
+type Agent struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	tasks []*Server
+}
+
+// NewAgent starts and returns a new Agent running tasks. The caller should
+// call Close when finished, to shut it down. The Servers don't need to be
+// started.
+func NewAgent(tasks ...*Server) *Agent {
+	a := &Agent{tasks: tasks}
+	a.Server = httptest.NewServer(a.Handler())
+	return a
+}
+
+// SetTasks replaces the tasks the agent runs, e.g. to simulate a task
+// stopping or being placed on the container instance.
+func (a *Agent) SetTasks(tasks ...*Server) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tasks = tasks
+}
+
+// Client returns an ecsmetadata.Client for the agent's introspection API.
+func (a *Agent) Client() *ecsmetadata.Client {
+	return ecsmetadata.NewClient(a.URL)
+}
+
+// DockerClient returns an ecsmetadata.Client for the agent's stand-in for the
+// Docker Engine API.
+func (a *Agent) DockerClient() *ecsmetadata.Client {
+	return ecsmetadata.NewClient(a.URL)
+}
+
+// Handler returns the http.Handler serving the agent's endpoints.
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tasks", func(w http.ResponseWriter, r *http.Request) {
+		resp := introspectionv1.TasksResponse{Tasks: []*introspectionv1.TaskResponse{}}
+		for _, s := range a.servers() {
+			resp.Tasks = append(resp.Tasks, s.introspectionTask())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("GET /containers/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if !slices.ContainsFunc(a.servers(), func(s *Server) bool { return s.hasContainer(id) }) {
+			http.NotFound(w, r)
+			return
+		}
+		var resp struct {
+			Config struct {
+				Env []string
+			}
+		}
+		resp.Config.Env = []string{"ECS_CONTAINER_METADATA_URI_V4=" + a.URL + "/v4/" + endpointID(id)}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/v4/{id}/", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		for _, s := range a.servers() {
+			if s.hasEndpoint(id) {
+				http.StripPrefix("/v4/"+id, s.Handler()).ServeHTTP(w, r)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+	return mux
+}
+
+func (a *Agent) servers() []*Server {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.tasks)
+}
+
+// introspectionTask returns the server's task as listed by the agent's
+// introspection API.
+func (s *Server) introspectionTask() *introspectionv1.TaskResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.decode()
+	if err != nil {
+		panic(err)
+	}
+	t := f.TaskMetadata
+	task := &introspectionv1.TaskResponse{
+		Arn:           t.TaskARN,
+		DesiredStatus: t.DesiredStatus,
+		KnownStatus:   t.KnownStatus,
+		Family:        t.Family,
+		Version:       t.Revision,
+		Containers:    []introspectionv1.ContainerResponse{},
+	}
+	for _, c := range t.Containers {
+		if c.ContainerResponse == nil {
+			continue
+		}
+		task.Containers = append(task.Containers, introspectionv1.ContainerResponse{
+			DockerID:   c.ID,
+			DockerName: c.DockerName,
+			Name:       c.Name,
+			Image:      c.Image,
+			ImageID:    c.ImageID,
+			Ports:      c.Ports,
+		})
+	}
+	return task
+}
+
+// endpointID returns the ID of the task metadata endpoint an Agent gives the
+// container with the given ID.
+func endpointID(containerID string) string {
+	sum := sha256.Sum256([]byte(containerID))
+	h := hex.EncodeToString(sum[:16])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// hasEndpoint reports whether the server's task has the container with the
+// given endpoint ID.
+func (s *Server) hasEndpoint(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.ContainsFunc(s.containers(), func(c map[string]interface{}) bool {
+		return endpointID(containerID(c)) == id
+	})
+}
+
+// hasContainer reports whether the server's task has the container with the
+// given ID.
+func (s *Server) hasContainer(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.ContainsFunc(s.containers(), func(c map[string]interface{}) bool {
+		return containerID(c) == id
+	})
+}
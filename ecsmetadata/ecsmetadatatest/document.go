@@ -0,0 +1,197 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadatatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// document is a JSON response body of the Server. It is served byte for byte
+// until the server changes it, so that fields the tmdsv4 types lack, or
+// would add, reach clients as they are in the fixture. The server changes a
+// generic tree of it, which keeps every field it doesn't touch.
+type document struct {
+	raw  []byte
+	tree interface{}
+}
+
+func newDocument(raw []byte) (*document, error) {
+	tree, err := decodeTree(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &document{raw: raw, tree: tree}, nil
+}
+
+// decodeTree decodes JSON into a generic tree, with numbers kept as written.
+func decodeTree(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// toTree returns the generic tree of v encoded as JSON.
+func toTree(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTree(data)
+}
+
+// bytes returns the body to serve.
+func (d *document) bytes() ([]byte, error) {
+	if d.raw != nil {
+		return d.raw, nil
+	}
+	return json.Marshal(d.tree)
+}
+
+// changed records that the tree was changed, so that the body is encoded
+// from it from now on.
+func (d *document) changed() {
+	d.raw = nil
+}
+
+// decode decodes the body into out.
+func (d *document) decode(out interface{}) error {
+	data, err := d.bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// patch applies to the tree the differences between the trees before and
+// after.
+func (d *document) patch(before, after interface{}) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	d.tree = patch(d.tree, before, after)
+	d.changed()
+}
+
+// patch returns tree with the differences between before and after applied:
+// object members and array elements which are equal in both are kept as they
+// are in tree.
+func patch(tree, before, after interface{}) interface{} {
+	switch a := after.(type) {
+	case map[string]interface{}:
+		b, ok := before.(map[string]interface{})
+		t, tok := tree.(map[string]interface{})
+		if !ok || !tok {
+			return after
+		}
+		for key, v := range a {
+			if !reflect.DeepEqual(b[key], v) {
+				t[key] = patch(t[key], b[key], v)
+			}
+		}
+		for key := range b {
+			if _, ok := a[key]; !ok {
+				delete(t, key)
+			}
+		}
+		return t
+	case []interface{}:
+		b, ok := before.([]interface{})
+		t, tok := tree.([]interface{})
+		if !ok || !tok || len(a) != len(b) || len(b) != len(t) {
+			return after
+		}
+		for i, v := range a {
+			if !reflect.DeepEqual(b[i], v) {
+				t[i] = patch(t[i], b[i], v)
+			}
+		}
+		return t
+	}
+	return after
+}
+
+// copyTree returns a deep copy of a generic tree.
+func copyTree(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, e := range v {
+			out[key] = copyTree(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = copyTree(e)
+		}
+		return out
+	}
+	return v
+}
+
+// object returns the object at path in v, or nil if there is none.
+func object(v interface{}, path ...string) map[string]interface{} {
+	for _, key := range path {
+		m, _ := v.(map[string]interface{})
+		v = m[key]
+	}
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func uintField(m map[string]interface{}, key string) uint64 {
+	n, _ := m[key].(json.Number)
+	u, _ := strconv.ParseUint(string(n), 10, 64)
+	return u
+}
+
+func floatField(m map[string]interface{}, key string) float64 {
+	n, _ := m[key].(json.Number)
+	f, _ := n.Float64()
+	return f
+}
+
+func timeField(m map[string]interface{}, key string) time.Time {
+	s, _ := m[key].(string)
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+// addUint adds delta to the number at key in m, if there is one. Fields
+// missing from a fixture stay missing.
+func addUint(m map[string]interface{}, key string, delta uint64) {
+	if _, ok := m[key]; ok {
+		m[key] = json.Number(strconv.FormatUint(uintField(m, key)+delta, 10))
+	}
+}
+
+// setFloat sets the number at key in m, if there is one.
+func setFloat(m map[string]interface{}, key string, f float64) {
+	if _, ok := m[key]; ok {
+		m[key] = json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+}
+
+// timeValue returns t as encoded in JSON by package time.
+func timeValue(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,116 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadatatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+)
+
+// Fixture is the state of an ECS task as served by the task metadata
+// endpoint.
+//
+// A Fixture read by LoadFixture is served as the bytes of its files, so that
+// fields the tmdsv4 types don't know, or would add when missing, reach
+// clients as recorded. Changes made to its fields after loading are applied
+// to the files' JSON like those of Server.Update.
+type Fixture struct {
+	// TaskMetadata is served from /task and /taskWithTags.
+	TaskMetadata *tmdsv4.TaskResponse
+	// TaskStats is served from /task/stats, keyed by container ID.
+	TaskStats map[string]*tmdsv4.StatsResponse
+
+	taskMetadataJSON, taskStatsJSON []byte
+}
+
+// LoadFixture reads a fixture from a /task response and a /task/stats
+// response saved as JSON files.
+func LoadFixture(taskMetadataPath, taskStatsPath string) (*Fixture, error) {
+	var (
+		f   Fixture
+		err error
+	)
+	if f.taskMetadataJSON, err = readJSON(taskMetadataPath, &f.TaskMetadata); err != nil {
+		return nil, fmt.Errorf("failed to read task metadata fixture: %w", err)
+	}
+	if f.taskStatsJSON, err = readJSON(taskStatsPath, &f.TaskStats); err != nil {
+		return nil, fmt.Errorf("failed to read task stats fixture: %w", err)
+	}
+	return &f, nil
+}
+
+// readJSON decodes the JSON file at path into out, and returns its bytes.
+func readJSON(path string, out interface{}) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return data, json.Unmarshal(data, out)
+}
+
+// documents returns the task metadata and task stats for a Server to serve
+// for f, which it never mutates.
+func (f *Fixture) documents() (task, stats *document, err error) {
+	if f.TaskMetadata == nil {
+		return nil, nil, fmt.Errorf("fixture has no task metadata")
+	}
+	taskStats := f.TaskStats
+	if taskStats == nil {
+		taskStats = make(map[string]*tmdsv4.StatsResponse)
+	}
+	if task, err = fixtureDocument(f.taskMetadataJSON, f.TaskMetadata, new(*tmdsv4.TaskResponse)); err != nil {
+		return nil, nil, err
+	}
+	if stats, err = fixtureDocument(f.taskStatsJSON, taskStats, new(map[string]*tmdsv4.StatsResponse)); err != nil {
+		return nil, nil, err
+	}
+	if object(stats.tree) == nil {
+		stats.tree = make(map[string]interface{})
+		stats.changed()
+	}
+	return task, stats, nil
+}
+
+// fixtureDocument returns the document for a field of a Fixture with the
+// current value v, loaded from raw if not nil. loaded is a pointer to decode
+// raw into, to find the changes made to v since.
+func fixtureDocument(raw []byte, v, loaded interface{}) (*document, error) {
+	if raw == nil {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return newDocument(data)
+	}
+	d, err := newDocument(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, loaded); err != nil {
+		return nil, err
+	}
+	before, err := toTree(loaded)
+	if err != nil {
+		return nil, err
+	}
+	after, err := toTree(v)
+	if err != nil {
+		return nil, err
+	}
+	d.patch(before, after)
+	return d, nil
+}
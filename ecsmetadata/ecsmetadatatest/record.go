@@ -0,0 +1,197 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadatatest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// Recording is a set of raw responses from a task metadata server, in the
+// layout of ecscollector/testdata/fixtures.
+type Recording struct {
+	// Name prefixes the file name of each response.
+	Name string
+	// Responses are the response bodies keyed by metadata server path.
+	Responses map[string][]byte
+}
+
+// recordedPaths maps each recorded metadata server path to the suffix of the
+// fixture file its response is saved in. Only /task and /task/stats are
+// required; /taskWithTags fails when the task role lacks permission to list
+// tags.
+var recordedPaths = []struct {
+	path     string
+	suffix   string
+	required bool
+}{
+	{"/task", "_task_metadata.json", true},
+	{"/taskWithTags", "_task_metadata_with_tags.json", false},
+	{"/task/stats", "_task_stats.json", true},
+}
+
+// Record retrieves the raw responses of the task metadata server. Optional
+// responses which can't be retrieved are left out of the recording.
+func Record(ctx context.Context, client *ecsmetadata.Client, name string) (*Recording, error) {
+	r := &Recording{Name: name, Responses: make(map[string][]byte)}
+	for _, p := range recordedPaths {
+		body, err := client.RetrieveRaw(ctx, p.path)
+		if err != nil {
+			if p.required {
+				return nil, err
+			}
+			continue
+		}
+		r.Responses[p.path] = body
+	}
+	return r, nil
+}
+
+// Redact replaces account IDs, IP addresses and host names derived from IP
+// addresses in every response with documentation values. Each distinct
+// address is mapped to the same replacement across all responses, so the
+// recording stays consistent with itself, and from run to run. Account IDs
+// are only replaced in ARNs, ECR image registries and account fields: the
+// names and IDs of clusters, services, tasks and containers are kept, as they
+// are tied to names elsewhere in the responses.
+func (r *Recording) Redact() {
+	red := newRedactor()
+	for _, path := range r.paths() {
+		r.Responses[path] = red.redact(r.Responses[path])
+	}
+}
+
+// paths returns the paths of the recorded responses, in the order of
+// recordedPaths and then sorted, so that addresses are numbered the same way
+// on every run.
+func (r *Recording) paths() []string {
+	var paths []string
+	for _, p := range recordedPaths {
+		if _, ok := r.Responses[p.path]; ok {
+			paths = append(paths, p.path)
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(r.Responses)) {
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// WriteDir saves every response as an indented JSON file in dir, and returns
+// the paths of the files written.
+func (r *Recording) WriteDir(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	var written []string
+	for _, p := range recordedPaths {
+		body, ok := r.Responses[p.path]
+		if !ok {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, body, "", "  "); err != nil {
+			return written, fmt.Errorf("response for %s is not valid JSON: %w", p.path, err)
+		}
+		buf.WriteByte('\n')
+		file := filepath.Join(dir, r.Name+p.suffix)
+		if err := os.WriteFile(file, buf.Bytes(), 0666); err != nil {
+			return written, err
+		}
+		written = append(written, file)
+	}
+	return written, nil
+}
+
+// redactedAccountID is the account ID used throughout the AWS documentation.
+const redactedAccountID = "123456789012"
+
+var (
+	jsonStringRE = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+	// Account IDs are only recognized where they're known to be, as any 12
+	// digit run of a hex container or task ID would otherwise be taken for
+	// one.
+	arnAccountIDRE   = regexp.MustCompile(`\b(arn:aws[a-z-]*:[a-z0-9-]*:[a-z0-9-]*:)[0-9]{12}:`)
+	ecrAccountIDRE   = regexp.MustCompile(`([^0-9A-Za-z])[0-9]{12}(\.dkr\.ecr\.)`)
+	fieldAccountIDRE = regexp.MustCompile(`("[A-Za-z]*Account(?:I[Dd])?"\s*:\s*")[0-9]{12}"`)
+	ipv4RE           = regexp.MustCompile(`\b[0-9]{1,3}(?:\.[0-9]{1,3}){3}\b`)
+	ipv4HostRE       = regexp.MustCompile(`\bip-[0-9]{1,3}(?:-[0-9]{1,3}){3}\b`)
+	ipv6RE           = regexp.MustCompile(`\b[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}\b`)
+)
+
+type redactor struct {
+	ips map[string]string
+}
+
+func newRedactor() *redactor {
+	return &redactor{ips: make(map[string]string)}
+}
+
+// redact rewrites the string literals of a JSON document, leaving its layout
+// and numbers untouched.
+func (r *redactor) redact(body []byte) []byte {
+	body = fieldAccountIDRE.ReplaceAll(body, []byte("${1}"+redactedAccountID+`"`))
+	return jsonStringRE.ReplaceAllFunc(body, func(s []byte) []byte {
+		str := string(s)
+		str = arnAccountIDRE.ReplaceAllString(str, "${1}"+redactedAccountID+":")
+		str = ecrAccountIDRE.ReplaceAllString(str, "${1}"+redactedAccountID+"${2}")
+		str = ipv4HostRE.ReplaceAllStringFunc(str, func(host string) string {
+			ip := strings.ReplaceAll(strings.TrimPrefix(host, "ip-"), "-", ".")
+			return "ip-" + strings.ReplaceAll(r.ip(ip), ".", "-")
+		})
+		str = ipv4RE.ReplaceAllStringFunc(str, r.ip)
+		str = ipv6RE.ReplaceAllStringFunc(str, r.ip)
+		return []byte(str)
+	})
+}
+
+// ip returns the replacement for addr, which is left alone if it's not an IP
+// address or not specific to the task, e.g. 0.0.0.0 or 127.0.0.1.
+func (r *redactor) ip(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() {
+		return addr
+	}
+	if replacement, ok := r.ips[addr]; ok {
+		return replacement
+	}
+	// Addresses from the benchmarking range of RFC 2544, which unlike the
+	// documentation ranges of RFC 5737 is large enough for every address of
+	// a task, and the documentation range of RFC 3849.
+	n := uint32(len(r.ips) + 1)
+	var replacement string
+	if ip.To4() != nil {
+		replacement = net.IPv4(198, 18+byte(n>>16&1), byte(n>>8), byte(n)).String()
+	} else {
+		v6 := net.ParseIP("2001:db8::")
+		binary.BigEndian.PutUint32(v6[12:], n)
+		replacement = v6.String()
+	}
+	r.ips[addr] = replacement
+	return replacement
+}
@@ -0,0 +1,89 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadatatest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	r := &Recording{Responses: map[string][]byte{
+		"/task": []byte(`{"TaskARN":"arn:aws:ecs:us-east-1:829490980523:task/c/1","Memory":829490980523,` +
+			`"DockerId":"1e1f2d3c4b5a829490980523aabbccdd","Image":"829490980523.dkr.ecr.us-east-1.amazonaws.com/app:1",` +
+			`"AccountId":"829490980523",` +
+			`"IPv4Addresses":["172.17.0.2","0.0.0.0"],"PrivateDNSName":"ip-172-17-0-2.ec2.internal",` +
+			`"IPv6Addresses":["2600:1f18:abcd::1"],"HostIp":"::"}`),
+		"/task/stats": []byte(`{"IP":"172.17.0.3","Other":"172.17.0.2"}`),
+	}}
+	r.Redact()
+
+	want := `{"TaskARN":"arn:aws:ecs:us-east-1:123456789012:task/c/1","Memory":829490980523,` +
+		`"DockerId":"1e1f2d3c4b5a829490980523aabbccdd","Image":"123456789012.dkr.ecr.us-east-1.amazonaws.com/app:1",` +
+		`"AccountId":"123456789012",` +
+		`"IPv4Addresses":["198.18.0.1","0.0.0.0"],"PrivateDNSName":"ip-198-18-0-1.ec2.internal",` +
+		`"IPv6Addresses":["2001:db8::2"],"HostIp":"::"}`
+	if got := string(r.Responses["/task"]); got != want {
+		t.Errorf("got redacted task\n%s\nwant\n%s", got, want)
+	}
+	want = `{"IP":"198.18.0.3","Other":"198.18.0.1"}`
+	if got := string(r.Responses["/task/stats"]); got != want {
+		t.Errorf("got redacted stats\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRedactManyAddresses(t *testing.T) {
+	r := newRedactor()
+	seen := make(map[string]string)
+	for i := range 1000 {
+		addr := fmt.Sprintf("10.0.%d.%d", i/250, i%250+1)
+		replacement := r.ip(addr)
+		if prev, ok := seen[replacement]; ok {
+			t.Fatalf("%s and %s both redacted to %s", prev, addr, replacement)
+		}
+		seen[replacement] = addr
+	}
+}
+
+func TestRecord(t *testing.T) {
+	s := newTestServer(t)
+	r, err := Record(context.Background(), s.Client(), "ec2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Redact()
+
+	dir := t.TempDir()
+	files, err := r.WriteDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("got %d files, want 3: %v", len(files), files)
+	}
+
+	fixture, err := LoadFixture(filepath.Join(dir, "ec2_task_metadata.json"), filepath.Join(dir, "ec2_task_stats.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arn := fixture.TaskMetadata.TaskARN; !strings.Contains(arn, redactedAccountID) {
+		t.Errorf("task ARN %q was not redacted", arn)
+	}
+	if len(fixture.TaskStats) != 3 {
+		t.Errorf("got stats for %d containers, want 3", len(fixture.TaskStats))
+	}
+}
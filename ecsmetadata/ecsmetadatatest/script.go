@@ -0,0 +1,93 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadatatest
+
+import "time"
+
+// A Step changes the state of a Server as part of a scripted scenario.
+type Step func(s *Server)
+
+// Script queues steps to be played back against the server. Each request
+// for /task, i.e. each scrape of a collector, first applies the next step, so
+// that a test can describe what a task does over a series of scrapes:
+//
+//	s.Script(
+//		ecsmetadatatest.Advance(15*time.Second),
+//		ecsmetadatatest.Do(
+//			ecsmetadatatest.Advance(15*time.Second),
+//			ecsmetadatatest.StopContainer("app", 137),
+//		),
+//		ecsmetadatatest.FailRequests(2, http.StatusServiceUnavailable),
+//	)
+//
+// Once the script is exhausted the server keeps serving its last state.
+func (s *Server) Script(steps ...Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, steps...)
+}
+
+// Do returns a Step applying all the given steps in order.
+func Do(steps ...Step) Step {
+	return func(s *Server) {
+		for _, step := range steps {
+			step(s)
+		}
+	}
+}
+
+// Wait is a Step that leaves the server unchanged.
+func Wait() Step {
+	return func(*Server) {}
+}
+
+// Advance returns a Step calling Server.Advance.
+func Advance(d time.Duration) Step {
+	return func(s *Server) { s.Advance(d) }
+}
+
+// StopContainer returns a Step calling Server.StopContainer. Unknown container
+// names are ignored.
+func StopContainer(name string, exitCode int) Step {
+	return func(s *Server) { _ = s.StopContainer(name, exitCode) }
+}
+
+// RestartContainer returns a Step calling Server.RestartContainer. Unknown
+// container names are ignored.
+func RestartContainer(name string) Step {
+	return func(s *Server) { _ = s.RestartContainer(name) }
+}
+
+// SetHealth returns a Step calling Server.SetHealth. Unknown container names
+// are ignored.
+func SetHealth(name, status string) Step {
+	return func(s *Server) { _ = s.SetHealth(name, status) }
+}
+
+// FailRequests returns a Step calling Server.FailRequests. The failures start
+// with the request that applied the step.
+func FailRequests(n, status int) Step {
+	return func(s *Server) { s.FailRequests(n, status) }
+}
+
+// MalformResponses returns a Step calling Server.MalformResponses. The
+// malformed responses start with the request that applied the step.
+func MalformResponses(n int) Step {
+	return func(s *Server) { s.MalformResponses(n) }
+}
+
+// SetLatency returns a Step calling Server.SetLatency.
+func SetLatency(d time.Duration) Step {
+	return func(s *Server) { s.SetLatency(d) }
+}
@@ -0,0 +1,466 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ecsmetadatatest provides a fake ECS task metadata server for
+// testing code that uses package ecsmetadata.
+//
+// The fake serves a Fixture, and can move its counters forward in time and
+// inject the failure modes seen on real tasks: containers stopping and
+// restarting, health check changes, error bursts, slow responses and
+// malformed JSON.
+package ecsmetadatatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// Server is a fake task metadata server. Its URL is the equivalent of
+// ECS_CONTAINER_METADATA_URI_V4 in a real task.
+type Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// task and stats are the /task and /task/stats responses.
+	task     *document
+	stats    *document
+	now      time.Time
+	rates    map[string]Rates
+	script   []Step
+	failures []int
+	malform  int
+	latency  time.Duration
+	jitter   float64
+	self     string
+	requests map[string]int
+}
+
+// Rates are the per-second rates at which Advance moves a container's
+// counters forward.
+type Rates struct {
+	// CPU is the number of vCPUs kept busy by the container.
+	CPU float64
+	// NetworkRxBytes and NetworkTxBytes are bytes received and transmitted
+	// per second on each of the container's interfaces.
+	NetworkRxBytes float64
+	NetworkTxBytes float64
+}
+
+// NewServer starts and returns a new Server serving f. The caller should
+// call Close when finished, to shut it down.
+func NewServer(f *Fixture) (*Server, error) {
+	s, err := NewUnstartedServer(f)
+	if err != nil {
+		return nil, err
+	}
+	s.Start()
+	return s, nil
+}
+
+// NewUnstartedServer returns a new Server serving f but doesn't start it,
+// like httptest.NewUnstartedServer.
+func NewUnstartedServer(f *Fixture) (*Server, error) {
+	task, stats, err := f.documents()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		task:     task,
+		stats:    stats,
+		rates:    make(map[string]Rates),
+		requests: make(map[string]int),
+	}
+	if _, err := s.decode(); err != nil {
+		return nil, fmt.Errorf("failed to decode fixture: %w", err)
+	}
+	for id, stats := range s.containerStats() {
+		// Pick up where the fixture was captured, at the rates observed
+		// in its last sampling interval.
+		if read := timeField(stats, "read"); read.After(s.now) {
+			s.now = read
+		}
+		s.rates[id] = fixtureRates(stats)
+	}
+	if s.now.IsZero() {
+		s.now = time.Now()
+	}
+	s.Server = httptest.NewUnstartedServer(s.Handler())
+	return s, nil
+}
+
+func fixtureRates(stats map[string]interface{}) Rates {
+	var r Rates
+	cpu := uintField(object(stats, "cpu_stats", "cpu_usage"), "total_usage")
+	preCPU := uintField(object(stats, "precpu_stats", "cpu_usage"), "total_usage")
+	if interval := timeField(stats, "read").Sub(timeField(stats, "preread")).Seconds(); interval > 0 && cpu >= preCPU {
+		r.CPU = float64(cpu-preCPU) / 1e9 / interval
+	}
+	if rates := object(stats, "network_rate_stats"); rates != nil {
+		r.NetworkRxBytes = floatField(rates, "rx_bytes_per_sec")
+		r.NetworkTxBytes = floatField(rates, "tx_bytes_per_sec")
+	}
+	return r
+}
+
+// Client returns an ecsmetadata.Client for the server.
+func (s *Server) Client() *ecsmetadata.Client {
+	return ecsmetadata.NewClient(s.URL)
+}
+
+// Handler returns the http.Handler serving the task metadata endpoint.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.serve(func() ([]byte, error) {
+		c, err := s.container(s.self)
+		if err != nil {
+			return nil, nil
+		}
+		return json.Marshal(c)
+	}))
+	mux.HandleFunc("GET /task", s.serve(s.task.bytes))
+	mux.HandleFunc("GET /taskWithTags", s.serve(s.task.bytes))
+	mux.HandleFunc("GET /task/stats", s.serve(s.stats.bytes))
+	return mux
+}
+
+// serve returns a handler serving the body returned by body, which is called
+// with s.mu held. A nil body is not found.
+func (s *Server) serve(body func() ([]byte, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		var step Step
+		if r.URL.Path == "/task" && len(s.script) > 0 {
+			step = s.script[0]
+			s.script = s.script[1:]
+		}
+		s.mu.Unlock()
+		if step != nil {
+			step(s)
+		}
+
+		s.mu.Lock()
+		latency := s.latency
+		var status int
+		if len(s.failures) > 0 {
+			status = s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		malform := s.malform > 0 && status == 0
+		if malform {
+			s.malform--
+		}
+		data, err := body()
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if data == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if status != 0 {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		if malform {
+			data = data[:len(data)/2]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+}
+
+// Requests returns the number of requests the server has received for the
+// given path, e.g. "/task/stats".
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Update calls fn with the server's fixture, which it may modify in place.
+// Only the fields fn changes are changed in the responses.
+func (s *Server) Update(fn func(f *Fixture)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.decode()
+	if err != nil {
+		panic(err)
+	}
+	task, stats := mustTree(f.TaskMetadata), mustTree(f.TaskStats)
+	fn(f)
+	s.task.patch(task, mustTree(f.TaskMetadata))
+	s.stats.patch(stats, mustTree(f.TaskStats))
+}
+
+// mustTree is like toTree for values known to encode.
+func mustTree(v interface{}) interface{} {
+	tree, err := toTree(v)
+	if err != nil {
+		panic(err)
+	}
+	return tree
+}
+
+// decode returns the server's fixture, decoded from its responses. It
+// always succeeds once NewUnstartedServer has checked it does. s.mu must be
+// held.
+func (s *Server) decode() (*Fixture, error) {
+	var f Fixture
+	if err := s.task.decode(&f.TaskMetadata); err != nil {
+		return nil, err
+	}
+	if err := s.stats.decode(&f.TaskStats); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Now returns the server's clock, which starts at the time the fixture's
+// stats were read and only moves forward with Advance.
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// SetRates overrides the rates at which Advance moves the counters of the
+// named container.
+func (s *Server) SetRates(name string, r Rates) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, err := s.container(name)
+	if err != nil {
+		return err
+	}
+	s.rates[containerID(c)] = r
+	return nil
+}
+
+// Advance moves the server's clock forward by d, and the counters of every
+// container that has stats along with it.
+func (s *Server) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+	// Network interfaces are usually shared by all containers of a task, so
+	// their rates vary together to keep the counters of each copy equal.
+	netScale := s.jitterScale()
+	for id, stats := range s.containerStats() {
+		r := s.rates[id]
+		r.CPU *= s.jitterScale()
+		r.NetworkRxBytes *= netScale
+		r.NetworkTxBytes *= netScale
+		stats["preread"] = stats["read"]
+		stats["read"] = timeValue(s.now)
+		if cpuStats := object(stats, "cpu_stats"); cpuStats != nil {
+			stats["precpu_stats"] = copyTree(cpuStats)
+			addUint(object(cpuStats, "cpu_usage"), "total_usage", uint64(r.CPU*float64(d)))
+			if uintField(cpuStats, "system_cpu_usage") != 0 {
+				cpus := max(uintField(cpuStats, "online_cpus"), 1)
+				addUint(cpuStats, "system_cpu_usage", cpus*uint64(d))
+			}
+		}
+		for _, v := range object(stats, "networks") {
+			net, _ := v.(map[string]interface{})
+			addUint(net, "rx_bytes", uint64(r.NetworkRxBytes*d.Seconds()))
+			addUint(net, "tx_bytes", uint64(r.NetworkTxBytes*d.Seconds()))
+			// Assume full-size frames; only the rough magnitude matters.
+			addUint(net, "rx_packets", uint64(r.NetworkRxBytes*d.Seconds()/1500))
+			addUint(net, "tx_packets", uint64(r.NetworkTxBytes*d.Seconds()/1500))
+		}
+		if rates := object(stats, "network_rate_stats"); rates != nil {
+			setFloat(rates, "rx_bytes_per_sec", r.NetworkRxBytes)
+			setFloat(rates, "tx_bytes_per_sec", r.NetworkTxBytes)
+		}
+	}
+	s.stats.changed()
+}
+
+func (s *Server) jitterScale() float64 {
+	if s.jitter == 0 {
+		return 1
+	}
+	return 1 + s.jitter*(2*rand.Float64()-1)
+}
+
+// SetJitter makes Advance vary each container's rates randomly by up to the
+// given fraction, e.g. 0.2 for ±20%, so that counters don't move in lockstep.
+func (s *Server) SetJitter(fraction float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jitter = fraction
+}
+
+// StopContainer marks the named container as stopped with the given exit
+// code. Like the real endpoint, its stats become empty.
+func (s *Server) StopContainer(name string, exitCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, err := s.container(name)
+	if err != nil {
+		return err
+	}
+	c["KnownStatus"] = "STOPPED"
+	c["ExitCode"] = json.Number(strconv.Itoa(exitCode))
+	c["FinishedAt"] = timeValue(s.now)
+	object(s.stats.tree)[containerID(c)] = map[string]interface{}{}
+	s.task.changed()
+	s.stats.changed()
+	return nil
+}
+
+// RestartContainer restarts the named container in place, as ECS does for
+// containers with a restart policy: its restart count is incremented and its
+// CPU usage starts again from zero.
+func (s *Server) RestartContainer(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, err := s.container(name)
+	if err != nil {
+		return err
+	}
+	count, _ := c["RestartCount"].(json.Number)
+	restarts, _ := count.Int64()
+	c["RestartCount"] = json.Number(strconv.FormatInt(restarts+1, 10))
+	c["KnownStatus"] = "RUNNING"
+	delete(c, "ExitCode")
+	c["StartedAt"] = timeValue(s.now)
+	delete(c, "FinishedAt")
+	if stats, ok := s.containerStats()[containerID(c)]; ok {
+		resetCPUUsage(object(stats, "cpu_stats", "cpu_usage"))
+		resetCPUUsage(object(stats, "precpu_stats", "cpu_usage"))
+	}
+	s.task.changed()
+	s.stats.changed()
+	return nil
+}
+
+// SetHealth sets the health status of the named container, e.g. "HEALTHY"
+// or "UNHEALTHY".
+func (s *Server) SetHealth(name, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, err := s.container(name)
+	if err != nil {
+		return err
+	}
+	c["Health"] = map[string]interface{}{"status": status, "statusSince": timeValue(s.now)}
+	s.task.changed()
+	return nil
+}
+
+// SetSelf makes the server's URL scoped to the named container, whose
+// metadata it then serves at the root like the container metadata endpoint.
+// Until SetSelf is called, the root is not found.
+func (s *Server) SetSelf(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.container(name); err != nil {
+		return err
+	}
+	s.self = name
+	return nil
+}
+
+// FailRequests makes the next n requests fail with the given HTTP status.
+func (s *Server) FailRequests(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range n {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// MalformResponses truncates the JSON body of the next n responses.
+func (s *Server) MalformResponses(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.malform += n
+}
+
+// SetLatency delays every response by d, until it is set back to zero.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// container returns the named container. s.mu must be held.
+func (s *Server) container(name string) (map[string]interface{}, error) {
+	for _, c := range s.containers() {
+		if c["Name"] == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("no container named %q in task", name)
+}
+
+// containers returns the containers of the task. s.mu must be held.
+func (s *Server) containers() []map[string]interface{} {
+	list, _ := object(s.task.tree)["Containers"].([]interface{})
+	var containers []map[string]interface{}
+	for _, v := range list {
+		if c, ok := v.(map[string]interface{}); ok {
+			containers = append(containers, c)
+		}
+	}
+	return containers
+}
+
+func containerID(c map[string]interface{}) string {
+	id, _ := c["DockerId"].(string)
+	return id
+}
+
+// containerStats returns the stats of the containers that have some, keyed
+// by container ID. s.mu must be held.
+func (s *Server) containerStats() map[string]map[string]interface{} {
+	out := make(map[string]map[string]interface{})
+	for id, v := range object(s.stats.tree) {
+		if stats, ok := v.(map[string]interface{}); ok && stats["read"] != nil {
+			out[id] = stats
+		}
+	}
+	return out
+}
+
+// resetCPUUsage sets the CPU usage counters of usage back to zero, and drops
+// the per-CPU ones.
+func resetCPUUsage(usage map[string]interface{}) {
+	for key, v := range usage {
+		if _, ok := v.(json.Number); ok {
+			usage[key] = json.Number("0")
+		} else {
+			delete(usage, key)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadatatest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+const (
+	ec2Metadata = "../../ecscollector/testdata/fixtures/ec2_task_metadata.json"
+	ec2Stats    = "../../ecscollector/testdata/fixtures/ec2_task_stats.json"
+
+	// DockerId of the "prometheus" container in the EC2 fixture.
+	prometheusID = "6b80adab0733f579594eccae31e5b0056b9544b805450ad6e278fed7f5e1c5ba"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	fixture, err := LoadFixture(ec2Metadata, ec2Stats)
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	s, err := NewServer(fixture)
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestScript(t *testing.T) {
+	s := newTestServer(t)
+	client := s.Client()
+	ctx := context.Background()
+
+	if err := s.SetRates("prometheus", Rates{CPU: 0.5, NetworkRxBytes: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	s.Script(
+		Advance(10*time.Second),
+		Do(RestartContainer("prometheus"), SetHealth("prometheus", "UNHEALTHY")),
+		Do(StopContainer("prometheus", 137), FailRequests(2, http.StatusServiceUnavailable)),
+		MalformResponses(1),
+	)
+
+	// Advance.
+	if _, err := client.RetrieveTaskMetadata(ctx); err != nil {
+		t.Fatal(err)
+	}
+	before, err := newTestServer(t).Client().RetrieveTaskStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := client.RetrieveTaskStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu := after[prometheusID].CPUStats.CPUUsage.TotalUsage - before[prometheusID].CPUStats.CPUUsage.TotalUsage
+	if want := uint64(5 * time.Second); cpu != want {
+		t.Errorf("CPU usage advanced by %d, want %d", cpu, want)
+	}
+	rx := after[prometheusID].Networks["eth0"].RxBytes - before[prometheusID].Networks["eth0"].RxBytes
+	if rx != 10000 {
+		t.Errorf("received bytes advanced by %d, want 10000", rx)
+	}
+
+	// Restart and health change.
+	task, err := client.RetrieveTaskMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := task.Containers[2]
+	if c.RestartCount == nil || *c.RestartCount != 1 {
+		t.Errorf("got restart count %v, want 1", c.RestartCount)
+	}
+	if c.Health == nil || c.Health.Status != "UNHEALTHY" {
+		t.Errorf("got health %v, want UNHEALTHY", c.Health)
+	}
+
+	// Stop, with the request applying the step failing along with the next.
+	if _, err := client.RetrieveTaskMetadata(ctx); err == nil {
+		t.Error("expected error from failed request")
+	}
+	if _, err := client.RetrieveTaskStats(ctx); err == nil {
+		t.Error("expected error from failed request")
+	}
+	stats, err := client.RetrieveTaskStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats[prometheusID].StatsJSON != nil {
+		t.Error("expected empty stats for stopped container")
+	}
+
+	// Malformed response, after which the script is exhausted.
+	if _, err := client.RetrieveTaskMetadata(ctx); err == nil {
+		t.Error("expected error from malformed response")
+	}
+	task, err = client.RetrieveTaskMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := task.Containers[2].KnownStatus; got != "STOPPED" {
+		t.Errorf("got known status %q, want STOPPED", got)
+	}
+	if got := s.Requests("/task"); got != 5 {
+		t.Errorf("got %d requests for /task, want 5", got)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	s := newTestServer(t)
+	s.SetLatency(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.Client().RetrieveTaskStats(ctx); err == nil {
+		t.Error("expected request to time out")
+	}
+}
+
+func get(t *testing.T, url string) []byte {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestServeVerbatim(t *testing.T) {
+	s := newTestServer(t)
+	for path, file := range map[string]string{"/task": ec2Metadata, "/task/stats": ec2Stats} {
+		want, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Reading the fixture changes nothing.
+		s.Update(func(f *Fixture) { _ = f.TaskMetadata.TaskARN })
+		if got := get(t, s.URL+path); !bytes.Equal(got, want) {
+			t.Errorf("%s isn't served as the fixture file", path)
+		}
+	}
+}
+
+func TestSchemaDrift(t *testing.T) {
+	task, err := os.ReadFile(ec2Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := os.ReadFile(ec2Stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rename a field, as AWS might, and drop one the tmdsv4 types would
+	// otherwise serve empty.
+	task = []byte(strings.Replace(string(task), `"TaskARN"`, `"TaskArn2"`, 1))
+	stats = []byte(strings.ReplaceAll(string(stats), `"total_usage"`, `"total_usage_v2"`))
+	dir := t.TempDir()
+	taskPath, statsPath := filepath.Join(dir, "task.json"), filepath.Join(dir, "stats.json")
+	if err := os.WriteFile(taskPath, task, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statsPath, stats, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := LoadFixture(taskPath, statsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Script(Do(Advance(10*time.Second), RestartContainer("prometheus"), SetHealth("prometheus", "HEALTHY")), StopContainer("prometheus", 0))
+	s.Update(func(f *Fixture) { f.TaskMetadata.Family += "-updated" })
+
+	client := s.Client()
+	client.SchemaMonitor = ecsmetadata.NewSchemaMonitor(nil)
+	for range 2 {
+		if _, err := client.RetrieveTaskMetadata(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.RetrieveTaskStats(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The stats of the stopped container are empty, and are not expected to
+	// have any field.
+	expected := `
+# HELP ecs_exporter_metadata_schema_missing_fields_total Total count of metadata server responses missing a field ecs_exporter relies on.
+# TYPE ecs_exporter_metadata_schema_missing_fields_total counter
+ecs_exporter_metadata_schema_missing_fields_total{endpoint="/task",field="TaskARN"} 2
+ecs_exporter_metadata_schema_missing_fields_total{endpoint="/task/stats",field="*.cpu_stats.cpu_usage.total_usage"} 2
+# HELP ecs_exporter_metadata_schema_unknown_fields_total Total count of metadata server responses containing a field unknown to ecs_exporter.
+# TYPE ecs_exporter_metadata_schema_unknown_fields_total counter
+ecs_exporter_metadata_schema_unknown_fields_total{endpoint="/task",field="TaskArn2"} 2
+ecs_exporter_metadata_schema_unknown_fields_total{endpoint="/task/stats",field="*.cpu_stats.cpu_usage.total_usage_v2"} 2
+ecs_exporter_metadata_schema_unknown_fields_total{endpoint="/task/stats",field="*.precpu_stats.cpu_usage.total_usage_v2"} 2
+`
+	if err := testutil.CollectAndCompare(client.SchemaMonitor, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+	body := string(get(t, s.URL+"/task"))
+	for _, want := range []string{`"Family":"prom-ecs-exporter-sandbox-main-ec2-updated"`, `"KnownStatus":"STOPPED"`, `"status":"HEALTHY"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/task lacks %s after the script:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadatatest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"go.yaml.in/yaml/v2"
+
+	"github.com/aws/amazon-ecs-agent/ecs-agent/stats"
+	"github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/response"
+	v2 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v2"
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+)
+
+// TaskDescription is a hand-written description of a task, as an
+// alternative to a Fixture recorded from a real one.
+type TaskDescription struct {
+	Cluster          string `yaml:"cluster"`
+	Family           string `yaml:"family"`
+	Revision         int    `yaml:"revision"`
+	LaunchType       string `yaml:"launch_type"`
+	AvailabilityZone string `yaml:"availability_zone"`
+	ServiceName      string `yaml:"service_name"`
+	// CPU is the task CPU limit in vCPUs, and Memory the task memory limit in
+	// MiB. Both are optional on EC2.
+	CPU    float64 `yaml:"cpu"`
+	Memory int64   `yaml:"memory"`
+	// Network is the traffic on the task's network interface, in bytes per
+	// second.
+	Network struct {
+		Rx float64 `yaml:"rx"`
+		Tx float64 `yaml:"tx"`
+	} `yaml:"network"`
+	Containers []ContainerDescription `yaml:"containers"`
+}
+
+// ContainerDescription describes a container of a TaskDescription.
+type ContainerDescription struct {
+	Name   string            `yaml:"name"`
+	Image  string            `yaml:"image"`
+	Type   string            `yaml:"type"`
+	Labels map[string]string `yaml:"labels"`
+	// CPU is the container CPU limit in CPU units, and Memory the container
+	// memory limit in MiB.
+	CPU    float64 `yaml:"cpu"`
+	Memory int64   `yaml:"memory"`
+	Health string  `yaml:"health"`
+	// Usage is what the container consumes: vCPUs kept busy and MiB of
+	// memory used.
+	Usage struct {
+		CPU    float64 `yaml:"cpu"`
+		Memory int64   `yaml:"memory"`
+	} `yaml:"usage"`
+}
+
+// LoadTaskDescription reads a TaskDescription from a YAML file.
+func LoadTaskDescription(path string) (*TaskDescription, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t TaskDescription
+	if err := yaml.UnmarshalStrict(data, &t); err != nil {
+		return nil, fmt.Errorf("failed to parse task description %s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// Fixture returns the state of the described task as of now.
+func (t *TaskDescription) Fixture(now time.Time) (*Fixture, error) {
+	if len(t.Containers) == 0 {
+		return nil, fmt.Errorf("task description has no containers")
+	}
+	launchType := strings.ToUpper(t.LaunchType)
+	if launchType == "" {
+		launchType = "FARGATE"
+	}
+	region := strings.TrimRight(t.AvailabilityZone, "abcdefghijklmnopqrstuvwxyz")
+	if region == "" {
+		region = "us-east-1"
+	}
+	arnPrefix := "arn:aws:ecs:" + region + ":" + redactedAccountID + ":"
+	taskID := fakeID(t.Cluster, t.Family)[:32]
+	taskARN := arnPrefix + "task/" + t.Cluster + "/" + taskID
+	started := now.Add(-time.Minute)
+	networkMode := "awsvpc"
+	if launchType == "EC2" {
+		networkMode = "bridge"
+	}
+
+	task := &tmdsv4.TaskResponse{
+		TaskResponse: &v2.TaskResponse{
+			Cluster:          t.Cluster,
+			TaskARN:          taskARN,
+			Family:           t.Family,
+			Revision:         strconv.Itoa(t.Revision),
+			DesiredStatus:    "RUNNING",
+			KnownStatus:      "RUNNING",
+			PullStartedAt:    &started,
+			PullStoppedAt:    &started,
+			AvailabilityZone: t.AvailabilityZone,
+			LaunchType:       launchType,
+		},
+		ServiceName: t.ServiceName,
+	}
+	if t.CPU != 0 || t.Memory != 0 {
+		task.Limits = &v2.LimitsResponse{}
+		if t.CPU != 0 {
+			task.Limits.CPU = &t.CPU
+		}
+		if t.Memory != 0 {
+			task.Limits.Memory = &t.Memory
+		}
+	}
+
+	f := &Fixture{TaskMetadata: task, TaskStats: make(map[string]*tmdsv4.StatsResponse)}
+	for i, c := range t.Containers {
+		if c.Name == "" {
+			return nil, fmt.Errorf("container %d has no name", i)
+		}
+		if t.Memory == 0 && c.Memory == 0 {
+			return nil, fmt.Errorf("container %q needs a memory limit when the task has none", c.Name)
+		}
+		id := fakeID(taskARN, c.Name)
+		containerType := c.Type
+		if containerType == "" {
+			containerType = "NORMAL"
+		}
+		labels := map[string]string{
+			"com.amazonaws.ecs.cluster":                 t.Cluster,
+			"com.amazonaws.ecs.container-name":          c.Name,
+			"com.amazonaws.ecs.task-arn":                taskARN,
+			"com.amazonaws.ecs.task-definition-family":  t.Family,
+			"com.amazonaws.ecs.task-definition-version": task.Revision,
+		}
+		for k, v := range c.Labels {
+			labels[k] = v
+		}
+		cr := tmdsv4.ContainerResponse{
+			ContainerResponse: &v2.ContainerResponse{
+				ID:            id,
+				Name:          c.Name,
+				DockerName:    c.Name,
+				Image:         c.Image,
+				Labels:        labels,
+				DesiredStatus: "RUNNING",
+				KnownStatus:   "RUNNING",
+				CreatedAt:     &started,
+				StartedAt:     &started,
+				Type:          containerType,
+				ContainerARN:  arnPrefix + "container/" + t.Cluster + "/" + taskID + "/" + id[:32],
+			},
+			Networks: []tmdsv4.Network{{Network: response.Network{
+				NetworkMode:   networkMode,
+				IPv4Addresses: []string{fmt.Sprintf("192.0.2.%d", i+2)},
+			}}},
+		}
+		if c.CPU != 0 {
+			cr.Limits.CPU = &c.CPU
+		}
+		if c.Memory != 0 {
+			cr.Limits.Memory = &c.Memory
+		}
+		if c.Health != "" {
+			cr.Health = &v2.HealthStatus{Status: c.Health, Since: &started}
+		}
+		f.TaskMetadata.Containers = append(f.TaskMetadata.Containers, cr)
+		f.TaskStats[id] = containerStats(id, c, t, now)
+	}
+	return f, nil
+}
+
+// containerStats returns stats for the container having run for one second
+// at the usage in its description, from which the Server derives its rates.
+func containerStats(id string, c ContainerDescription, t *TaskDescription, now time.Time) *tmdsv4.StatsResponse {
+	s := &tmdsv4.StatsResponse{
+		StatsJSON: &container.StatsResponse{
+			Name: "/" + c.Name,
+			ID:   id,
+			Networks: map[string]container.NetworkStats{
+				"eth1": {},
+			},
+		},
+		Network_rate_stats: &stats.NetworkStatsPerSec{
+			RxBytesPerSecond: t.Network.Rx,
+			TxBytesPerSecond: t.Network.Tx,
+		},
+	}
+	s.Read = now
+	s.PreRead = now.Add(-time.Second)
+	s.CPUStats.CPUUsage.TotalUsage = uint64(c.Usage.CPU * float64(time.Second))
+	s.CPUStats.OnlineCPUs = 2
+	s.MemoryStats.Usage = uint64(c.Usage.Memory * 1024 * 1024)
+	s.MemoryStats.Stats = map[string]uint64{"cache": 0}
+	return s
+}
+
+func fakeID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "/")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,57 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadatatest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTaskDescription(t *testing.T) {
+	desc, err := LoadTaskDescription("testdata/task.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := desc.Fixture(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Advance(10 * time.Second)
+
+	ctx := context.Background()
+	task, err := s.Client().RetrieveTaskMetadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Revision != "3" || task.LaunchType != "FARGATE" || len(task.Containers) != 2 {
+		t.Errorf("unexpected task: %+v", task)
+	}
+	stats, err := s.Client().RetrieveTaskStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := stats[task.Containers[0].ID]
+	if got, want := app.CPUStats.CPUUsage.TotalUsage, uint64(2750*time.Millisecond); got != want {
+		t.Errorf("got CPU usage %d, want %d", got, want)
+	}
+	if got := app.Networks["eth1"].RxBytes; got != 200000 {
+		t.Errorf("got received bytes %d, want 200000", got)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadata
+
+import (
+	"encoding"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// expectedFields are the fields of each response that the exporter relies on,
+// and are thus reported when missing. In paths, "[]" stands for every element
+// of an array and "*" for every value of an object used as a map. Empty
+// objects in maps are skipped; /task/stats serves these for stopped
+// containers.
+var expectedFields = map[string][]string{
+	"/task": {
+		"Cluster",
+		"TaskARN",
+		"Family",
+		"Revision",
+		"KnownStatus",
+		"Containers",
+		"Containers[].DockerId",
+		"Containers[].Name",
+		"Containers[].Limits",
+	},
+	"/task/stats": {
+		"*.read",
+		"*.cpu_stats.cpu_usage.total_usage",
+		"*.memory_stats.usage",
+		"*.memory_stats.stats.cache",
+	},
+}
+
+// expectedFieldConditions maps expected fields which only some responses have
+// to the field, relative to the element matched by the last wildcard of their
+// path, that they are expected along with. The "cache" memory stat is only
+// served on cgroup v1 hosts, which also serve "total_cache"; cgroup v2 hosts
+// have neither.
+var expectedFieldConditions = map[string]string{
+	"*.memory_stats.stats.cache": "memory_stats.stats.total_cache",
+}
+
+// SchemaMonitor detects drift between the responses of the metadata server
+// and the types they're decoded into, which would otherwise go unnoticed:
+// unknown fields are ignored, and missing fields are decoded as zero values.
+// It is a prometheus.Collector counting the responses with each unknown and
+// missing field.
+type SchemaMonitor struct {
+	logger  *slog.Logger
+	unknown *prometheus.CounterVec
+	missing *prometheus.CounterVec
+
+	mu     sync.Mutex
+	logged map[string]bool
+}
+
+// NewSchemaMonitor returns a new SchemaMonitor. If logger is not nil, each
+// unknown or missing field is logged the first time it is seen.
+func NewSchemaMonitor(logger *slog.Logger) *SchemaMonitor {
+	return &SchemaMonitor{
+		logger: logger,
+		unknown: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ecs_exporter_metadata_schema_unknown_fields_total",
+			Help: "Total count of metadata server responses containing a field unknown to ecs_exporter.",
+		}, []string{"endpoint", "field"}),
+		missing: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ecs_exporter_metadata_schema_missing_fields_total",
+			Help: "Total count of metadata server responses missing a field ecs_exporter relies on.",
+		}, []string{"endpoint", "field"}),
+		logged: make(map[string]bool),
+	}
+}
+
+func (m *SchemaMonitor) Describe(ch chan<- *prometheus.Desc) {
+	m.unknown.Describe(ch)
+	m.missing.Describe(ch)
+}
+
+func (m *SchemaMonitor) Collect(ch chan<- prometheus.Metric) {
+	m.unknown.Collect(ch)
+	m.missing.Collect(ch)
+}
+
+// check compares the response body for endpoint to the type t it has been
+// successfully decoded into.
+func (m *SchemaMonitor) check(endpoint string, body []byte, t reflect.Type) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return
+	}
+
+	unknown := make(map[string]bool)
+	findUnknown(v, t, "", unknown)
+	for field := range unknown {
+		m.unknown.WithLabelValues(endpoint, field).Inc()
+		m.logOnce("Unknown field in metadata server response", endpoint, field)
+	}
+
+	for _, field := range expectedFields[endpoint] {
+		var when []string
+		if condition, ok := expectedFieldConditions[field]; ok {
+			when = strings.Split(condition, ".")
+		}
+		if !present(v, strings.Split(field, "."), when) {
+			m.missing.WithLabelValues(endpoint, field).Inc()
+			m.logOnce("Expected field missing from metadata server response", endpoint, field)
+		}
+	}
+}
+
+func (m *SchemaMonitor) logOnce(msg, endpoint, field string) {
+	if m.logger == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := msg + endpoint + field
+	if m.logged[key] {
+		return
+	}
+	m.logged[key] = true
+	m.logger.Warn(msg, "endpoint", endpoint, "field", field)
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// findUnknown adds the path of every field of v that has no counterpart in t
+// to unknown.
+func findUnknown(v interface{}, t reflect.Type, path string, unknown map[string]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// Types decoding themselves, like time.Time, are opaque.
+	if reflect.PointerTo(t).Implements(unmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		for key, value := range obj {
+			field, ok := fields[strings.ToLower(key)]
+			if !ok {
+				unknown[join(path, key)] = true
+				continue
+			}
+			findUnknown(value, field, join(path, key), unknown)
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, value := range obj {
+			findUnknown(value, t.Elem(), join(path, "*"), unknown)
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		for _, value := range arr {
+			findUnknown(value, t.Elem(), path+"[]", unknown)
+		}
+	}
+}
+
+// jsonFields returns the types of the fields of struct type t by their
+// lowercased JSON name, including fields promoted from embedded structs.
+// Like encoding/json, shallower fields take precedence.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f.Type
+	}
+	for _, et := range embedded {
+		for name, ft := range jsonFields(et) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = ft
+			}
+		}
+	}
+	return fields
+}
+
+// present reports whether the field at path exists in v. If when is set, the
+// field is only looked for in the values matched by the last wildcard of path
+// which have the field at when.
+func present(v interface{}, path, when []string) bool {
+	if when != nil && !slices.ContainsFunc(path, isWildcard) {
+		if !present(v, when, nil) {
+			return true
+		}
+		when = nil
+	}
+	if len(path) == 0 {
+		return true
+	}
+	key := path[0]
+	if name, ok := strings.CutSuffix(key, "[]"); ok {
+		obj, _ := v.(map[string]interface{})
+		arr, ok := obj[name].([]interface{})
+		if !ok {
+			return false
+		}
+		for _, elem := range arr {
+			if !present(elem, path[1:], when) {
+				return false
+			}
+		}
+		return true
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if key == "*" {
+		for _, value := range obj {
+			if m, ok := value.(map[string]interface{}); !ok || len(m) == 0 {
+				continue
+			}
+			if !present(value, path[1:], when) {
+				return false
+			}
+		}
+		return true
+	}
+	value, ok := obj[key]
+	return ok && present(value, path[1:], when)
+}
+
+func isWildcard(key string) bool {
+	return key == "*" || strings.HasSuffix(key, "[]")
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
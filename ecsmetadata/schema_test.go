@@ -0,0 +1,97 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecsmetadata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSchemaMonitor(t *testing.T) {
+	// The EC2 fixture was recorded on cgroup v2, which has no "cache" memory
+	// stat, and must not be reported as missing it.
+	for _, name := range []string{"ec2", "fargate"} {
+		t.Run(name, func(t *testing.T) {
+			task, err := os.ReadFile("../ecscollector/testdata/fixtures/" + name + "_task_metadata.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, err := os.ReadFile("../ecscollector/testdata/fixtures/" + name + "_task_stats.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Rename a field, as AWS might.
+			task = []byte(strings.Replace(string(task), `"TaskARN"`, `"TaskArn2"`, 1))
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /task", func(w http.ResponseWriter, r *http.Request) { w.Write(task) })
+			mux.HandleFunc("GET /task/stats", func(w http.ResponseWriter, r *http.Request) { w.Write(stats) })
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			client := NewClient(server.URL)
+			client.SchemaMonitor = NewSchemaMonitor(nil)
+			for range 2 {
+				if _, err := client.RetrieveTaskMetadata(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := client.RetrieveTaskStats(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected := `
+# HELP ecs_exporter_metadata_schema_missing_fields_total Total count of metadata server responses missing a field ecs_exporter relies on.
+# TYPE ecs_exporter_metadata_schema_missing_fields_total counter
+ecs_exporter_metadata_schema_missing_fields_total{endpoint="/task",field="TaskARN"} 2
+# HELP ecs_exporter_metadata_schema_unknown_fields_total Total count of metadata server responses containing a field unknown to ecs_exporter.
+# TYPE ecs_exporter_metadata_schema_unknown_fields_total counter
+ecs_exporter_metadata_schema_unknown_fields_total{endpoint="/task",field="TaskArn2"} 2
+`
+			if err := testutil.CollectAndCompare(client.SchemaMonitor, strings.NewReader(expected)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPresentWhen(t *testing.T) {
+	field := strings.Split("*.memory_stats.stats.cache", ".")
+	when := strings.Split("memory_stats.stats.total_cache", ".")
+	for _, tc := range []struct {
+		body string
+		want bool
+	}{
+		{`{"a":{"memory_stats":{"stats":{"cache":1,"total_cache":1}}}}`, true},
+		// cgroup v2.
+		{`{"a":{"memory_stats":{"stats":{"file":1}}}}`, true},
+		// cgroup v1 without "cache".
+		{`{"a":{"memory_stats":{"stats":{"total_cache":1}}},"b":{"memory_stats":{"stats":{"file":1}}}}`, false},
+	} {
+		var v interface{}
+		if err := json.Unmarshal([]byte(tc.body), &v); err != nil {
+			t.Fatal(err)
+		}
+		if got := present(v, field, when); got != tc.want {
+			t.Errorf("present(%s) = %v, want %v", tc.body, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/proxy"
+	"github.com/prometheus-community/ecs_exporter/relabel"
+)
+
+// metricsHandler serves the metrics of the ECS collector along with the
+// exporter's own metrics. Like node_exporter, the ECS metrics can be
+// restricted to some collectors per request with collect[] query parameters,
+// e.g. /metrics?collect[]=container_cpu&collect[]=container_memory. The
+// metrics of proxied targets are left out of such requests.
+type metricsHandler struct {
+	exporterGatherer prometheus.Gatherer
+	opts             promhttp.HandlerOpts
+
+	mu             sync.RWMutex
+	collector      *ecscollector.Collector
+	relabelConfigs []*relabel.Config
+	gatherer       prometheus.Gatherer
+	unfiltered     http.Handler
+}
+
+func newMetricsHandler(collector *ecscollector.Collector, p *proxy.Proxy, relabelConfigs []*relabel.Config, exporterGatherer prometheus.Gatherer, opts promhttp.HandlerOpts) *metricsHandler {
+	h := &metricsHandler{
+		exporterGatherer: exporterGatherer,
+		opts:             opts,
+	}
+	h.setCollector(collector, p, relabelConfigs)
+	return h
+}
+
+// setCollector replaces the ECS collector, the proxy and the relabeling rules
+// applied to all metrics, e.g. on configuration reload. The new collector
+// carries on with the container history of the previous one.
+func (h *metricsHandler) setCollector(collector *ecscollector.Collector, p *proxy.Proxy, relabelConfigs []*relabel.Config) {
+	gatherer := gathererFor(collector, p, relabelConfigs, h.exporterGatherer)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	collector.KeepHistory(h.collector)
+	h.collector = collector
+	h.relabelConfigs = relabelConfigs
+	h.gatherer = gatherer
+	h.unfiltered = promhttp.HandlerFor(gatherer, h.opts)
+}
+
+// Gather gathers the metrics served without collect[] parameters, for the
+// push outputs.
+func (h *metricsHandler) Gather() ([]*dto.MetricFamily, error) {
+	h.mu.RLock()
+	gatherer := h.gatherer
+	h.mu.RUnlock()
+	return gatherer.Gather()
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	collector, relabelConfigs, unfiltered := h.collector, h.relabelConfigs, h.unfiltered
+	h.mu.RUnlock()
+
+	filters := r.URL.Query()["collect[]"]
+	if len(filters) == 0 {
+		unfiltered.ServeHTTP(w, r)
+		return
+	}
+	filtered, err := collector.Filter(filters)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Couldn't create filtered metrics handler: %s", err), http.StatusBadRequest)
+		return
+	}
+	promhttp.HandlerFor(gathererFor(filtered, nil, relabelConfigs, h.exporterGatherer), h.opts).ServeHTTP(w, r)
+}
+
+func gathererFor(collector prometheus.Collector, p *proxy.Proxy, relabelConfigs []*relabel.Config, exporterGatherer prometheus.Gatherer) prometheus.Gatherer {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	return relabel.Gatherer(proxy.Gatherer(prometheus.Gatherers{exporterGatherer, registry}, p), relabelConfigs)
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
+	"github.com/prometheus-community/ecs_exporter/relabel"
+)
+
+func newTestServer(t *testing.T) *ecsmetadatatest.Server {
+	t.Helper()
+	fixture, err := ecsmetadatatest.LoadFixture(
+		"ecscollector/testdata/fixtures/fargate_task_metadata.json",
+		"ecscollector/testdata/fixtures/fargate_task_stats.json",
+	)
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	s, err := ecsmetadatatest.NewServer(fixture)
+	if err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestCollector(t *testing.T, s *ecsmetadatatest.Server, collectors ...string) *ecscollector.Collector {
+	t.Helper()
+	c, err := ecscollector.NewCollector(s.Client(), slog.Default(), ecscollector.Opts{Collectors: collectors})
+	if err != nil {
+		t.Fatalf("failed to create collector: %v", err)
+	}
+	return c
+}
+
+// get returns the status code and body of the handler's response to a GET
+// request for target.
+func get(t *testing.T, h http.Handler, target string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	body, err := io.ReadAll(rec.Result().Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	return rec.Code, string(body)
+}
+
+// families returns the names of the metric families gathered by g.
+func families(t *testing.T, g prometheus.Gatherer) map[string]bool {
+	t.Helper()
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, mf := range mfs {
+		names[mf.GetName()] = true
+	}
+	return names
+}
+
+func TestSetCollector(t *testing.T) {
+	s := newTestServer(t)
+	h := newMetricsHandler(newTestCollector(t, s, "container_cpu"), nil, nil, prometheus.NewRegistry(), promhttp.HandlerOpts{})
+
+	_, body := get(t, h, "/metrics")
+	if !strings.Contains(body, "ecs_container_cpu_usage_seconds_total{") {
+		t.Errorf("metrics lack the CPU metrics of the first collector:\n%s", body)
+	}
+
+	env := relabel.DefaultConfig
+	env.TargetLabel = "env"
+	env.Replacement = "test"
+	h.setCollector(newTestCollector(t, s, "container_memory"), nil, []*relabel.Config{&env})
+
+	code, body := get(t, h, "/metrics")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d, want %d:\n%s", code, http.StatusOK, body)
+	}
+	if strings.Contains(body, "ecs_container_cpu_usage_seconds_total") {
+		t.Errorf("metrics still have the CPU metrics of the replaced collector:\n%s", body)
+	}
+	if !strings.Contains(body, `ecs_container_memory_usage_bytes{container_name="`) || !strings.Contains(body, `env="test"`) {
+		t.Errorf("metrics lack the relabeled memory metrics of the new collector:\n%s", body)
+	}
+	if names := families(t, h); names["ecs_container_cpu_usage_seconds_total"] || !names["ecs_container_memory_usage_bytes"] {
+		t.Errorf("Gather returned %v, want the metrics of the new collector", names)
+	}
+	// collect[] filters the new collector too.
+	if code, _ := get(t, h, "/metrics?collect[]=container_cpu"); code != http.StatusBadRequest {
+		t.Errorf("got status %d for a collector no longer enabled, want %d", code, http.StatusBadRequest)
+	}
+	if code, body := get(t, h, "/metrics?collect[]=container_memory"); code != http.StatusOK || !strings.Contains(body, `env="test"`) {
+		t.Errorf("got status %d for the new collector, want %d with relabeled metrics:\n%s", code, http.StatusOK, body)
+	}
+}
+
+func TestCollectFilter(t *testing.T) {
+	s := newTestServer(t)
+	enabled := []string{"container_cpu", "container_memory", "network", "task"}
+	exporter := prometheus.NewRegistry()
+	exporter.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "ecs_exporter_test"}))
+	h := newMetricsHandler(newTestCollector(t, s, enabled...), nil, nil, exporter, promhttp.HandlerOpts{})
+
+	tests := []struct {
+		name  string
+		query string
+		// collectors are those whose metrics are expected on success.
+		collectors []string
+		// err is expected in the body of a 400 response.
+		err string
+	}{
+		{
+			name:       "unfiltered",
+			collectors: enabled,
+		},
+		{
+			name:       "one collector",
+			query:      "collect[]=container_cpu",
+			collectors: []string{"container_cpu"},
+		},
+		{
+			name:       "several collectors",
+			query:      "collect[]=container_memory&collect[]=network",
+			collectors: []string{"container_memory", "network"},
+		},
+		{
+			name:       "repeated collector",
+			query:      "collect[]=task&collect[]=task",
+			collectors: []string{"task"},
+		},
+		{
+			name:  "unknown collector",
+			query: "collect[]=container_gpu",
+			err:   `collector "container_gpu" is not enabled`,
+		},
+		{
+			name:  "disabled collector",
+			query: "collect[]=blkio",
+			err:   `collector "blkio" is not enabled`,
+		},
+		{
+			name:  "unknown among enabled collectors",
+			query: "collect[]=container_cpu&collect[]=container_gpu",
+			err:   `collector "container_gpu" is not enabled`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, body := get(t, h, "/metrics?"+test.query)
+			if test.err != "" {
+				if code != http.StatusBadRequest {
+					t.Errorf("got status %d, want %d", code, http.StatusBadRequest)
+				}
+				if !strings.Contains(body, test.err) {
+					t.Errorf("got body %q, want it to contain %q", body, test.err)
+				}
+				return
+			}
+			if code != http.StatusOK {
+				t.Fatalf("got status %d, want %d:\n%s", code, http.StatusOK, body)
+			}
+			parser := expfmt.NewTextParser(model.UTF8Validation)
+			parsed, err := parser.TextToMetricFamilies(strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("failed to parse metrics: %v", err)
+			}
+			got := make(map[string]bool)
+			for name := range parsed {
+				got[name] = true
+			}
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(newTestCollector(t, s, test.collectors...))
+			want := families(t, registry)
+			want["ecs_exporter_test"] = true
+			if !maps.Equal(got, want) {
+				t.Errorf("got metric families %v, want %v", slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+			}
+		})
+	}
+}
@@ -14,9 +14,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus/client_golang/prometheus"
@@ -31,10 +40,16 @@ import (
 
 	"github.com/prometheus-community/ecs_exporter/ecscollector"
 	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+	"github.com/prometheus-community/ecs_exporter/output"
+	"github.com/prometheus-community/ecs_exporter/proxy"
 )
 
 const exporter = "ecs_exporter"
 
+// shutdownTimeout is how long in-flight requests are given to complete on
+// shutdown, after the drain window.
+const shutdownTimeout = 5 * time.Second
+
 func main() {
 	promslogConfig := &promslog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
@@ -44,25 +59,217 @@ func main() {
 		"web.disable-exporter-metrics",
 		"Exclude metrics about the exporter itself (promhttp_*, process_*, go_*).",
 	).Bool()
+	logSchemaDrift := kingpin.Flag(
+		"metadata.log-schema-drift",
+		"Log each unknown or missing field in task metadata responses the first time it is seen.",
+	).Bool()
+	statsTimestamps := kingpin.Flag(
+		"metadata.stats-timestamps",
+		"Timestamp container metrics derived from container stats with the time the ECS agent read the stats, instead of the scrape time.",
+	).Bool()
+	collectorFlags := make(map[string]*bool)
+	for _, info := range ecscollector.AvailableCollectors() {
+		defaultState := "disabled"
+		if info.DefaultEnabled {
+			defaultState = "enabled"
+		}
+		collectorFlags[info.Name] = kingpin.Flag(
+			"collector."+info.Name,
+			fmt.Sprintf("Enable the %s collector (default: %s). %s", info.Name, defaultState, info.Help),
+		).Default(strconv.FormatBool(info.DefaultEnabled)).Bool()
+	}
+	taskLabels := kingpin.Flag(
+		"labels.task",
+		"Comma-separated task-level labels to add to every metric, among "+strings.Join(ecscollector.TaskLabels(), ", ")+".",
+	).String()
+	containerLabels := kingpin.Flag(
+		"labels.container",
+		"Comma-separated Docker labels of containers to add to container-level metrics, each as <docker label>[=<label name>]. Unless renamed, labels are named container_label_<docker label>, with invalid characters replaced by underscores.",
+	).String()
+	includeContainers := kingpin.Flag("containers.include", "Regexp of container names to produce metrics for, anchored at both ends.").String()
+	excludeContainers := kingpin.Flag("containers.exclude", "Regexp of container names to drop the metrics of, anchored at both ends.").String()
+	includeContainerTypes := kingpin.Flag("containers.include-types", "Comma-separated container types to produce metrics for, e.g. NORMAL.").String()
+	excludeContainerTypes := kingpin.Flag("containers.exclude-types", "Comma-separated container types to drop the metrics of.").String()
+	excludeSelf := kingpin.Flag("containers.exclude-self", "Drop the metrics of the container ecs_exporter runs in.").Bool()
+	stoppedRetention := kingpin.Flag(
+		"containers.stopped-retention",
+		"How long to keep exposing the final counters of stopped containers for, from their last stats, so that rates include their last increment. Zero disables this.",
+	).Default("0s").Duration()
+	createdSamples := kingpin.Flag(
+		"web.openmetrics-created-samples",
+		"Expose the created timestamps of counters as _created samples in the OpenMetrics text format. Only enable this if Prometheus ingests them as created timestamps, with the created-timestamp-zero-ingestion feature flag. Created timestamps are always included in the protobuf format.",
+	).Bool()
+	drainWindow := kingpin.Flag(
+		"web.drain-window",
+		"How long to keep serving metrics for after receiving SIGTERM, which ECS sends when stopping the task, unless a full scrape succeeds first. It should be shorter than the container's stopTimeout.",
+	).Default("0s").Duration()
+	sdPath := kingpin.Flag(
+		"web.sd-path",
+		"Path under which to serve the metrics endpoints of the task's containers, selected by their prometheus.io/port Docker label, for Prometheus' HTTP service discovery, e.g. /sd. This exposes the containers' addresses, images and Docker labels without authentication, unless the web config requires it. Empty disables it.",
+	).Default("").String()
+	sdHostAddress := kingpin.Flag(
+		"sd.host-address",
+		"Address of the container instance, on which containers in bridge or host network mode are discovered, unless their port is mapped to a specific address.",
+	).String()
+	daemon := kingpin.Flag(
+		"daemon",
+		"Monitor every task on the EC2 container instance, as listed by the ECS agent, instead of the task ecs_exporter runs in. The exporter must run in host network mode, e.g. as a daemon service.",
+	).Bool()
+	daemonAgentURL := kingpin.Flag("daemon.agent-url", "URL of the ECS agent's introspection API, in daemon mode.").Default("http://localhost:51678").String()
+	daemonDockerHost := kingpin.Flag(
+		"daemon.docker-host",
+		"Docker Engine API, as a unix:// socket or http:// URL, in whose container environments the task metadata endpoints are looked up, in daemon mode.",
+	).Default("unix:///var/run/docker.sock").String()
+	configFile := kingpin.Flag(
+		"config.file",
+		"Path to a YAML configuration file, overriding the corresponding flags. It is reloaded on SIGHUP and on POST /-/reload.",
+	).String()
 	toolkitFlags := kingpinflag.AddFlags(kingpin.CommandLine, ":9779")
 
+	kingpin.Command("serve", "Serve ECS task metrics. This is the default.").Default()
+	recordCmd := kingpin.Command("record", "Save raw task metadata responses as test fixtures, in the layout of ecscollector/testdata/fixtures.")
+	recordOutputDir := recordCmd.Flag("record.output-dir", "Directory to save the fixtures in.").Default(".").String()
+	recordName := recordCmd.Flag("record.name", "Name prefixing the file name of each fixture.").Default("recorded").String()
+	recordRedact := recordCmd.Flag("record.redact", "Replace account IDs and IP addresses in the fixtures with documentation values. Cluster and service names and task IDs are kept.").Bool()
+	simulateCmd := kingpin.Command("simulate", "Serve metrics of a simulated task, from a fake task metadata endpoint also served by the exporter.")
+	simulateFixture := simulateCmd.Flag("simulate.fixture", "Path prefix of a recorded fixture, e.g. ecscollector/testdata/fixtures/fargate.").String()
+	simulateTaskFile := simulateCmd.Flag("simulate.task-file", "Path to a YAML description of the task to simulate.").String()
+	simulateListenAddress := simulateCmd.Flag("simulate.listen-address", "Address on which to serve the fake task metadata endpoint.").Default("localhost:9780").String()
+	simulateJitter := simulateCmd.Flag("simulate.jitter", "Fraction by which CPU and network usage randomly vary over time.").Default("0.2").Float64()
+
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(versioncollector.NewCollector(exporter))
 	kingpin.Version(version.Print(exporter))
 
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	command := kingpin.Parse()
 
 	logger := promslog.New(promslogConfig)
 
-	client, err := ecsmetadata.NewClientFromEnvironment()
+	if command == recordCmd.FullCommand() {
+		if err := record(logger, *recordOutputDir, *recordName, *recordRedact); err != nil {
+			logger.Error("Error recording fixtures", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var client *ecsmetadata.Client
+	if command == simulateCmd.FullCommand() {
+		simulateCtx, stopSimulation := context.WithCancel(context.Background())
+		server, err := simulate(simulateCtx, logger, *simulateFixture, *simulateTaskFile, *simulateListenAddress, *simulateJitter)
+		if err != nil {
+			logger.Error("Error starting simulation", "err", err)
+			os.Exit(1)
+		}
+		defer server.Close()
+		defer stopSimulation()
+		client = server.Client()
+	} else {
+		var err error
+		client, err = ecsmetadata.NewClientFromEnvironment()
+		if err != nil {
+			logger.Error("Error creating client", "error", err)
+			os.Exit(1)
+		}
+	}
+	var schemaLogger *slog.Logger
+	if *logSchemaDrift {
+		schemaLogger = logger
+	}
+	client.SchemaMonitor = ecsmetadata.NewSchemaMonitor(schemaLogger)
+	registry.MustRegister(client.SchemaMonitor)
+	var agentClient, dockerClient *ecsmetadata.Client
+	if *daemon {
+		if *sdPath != "" {
+			logger.Error("Service discovery is not supported in daemon mode")
+			os.Exit(1)
+		}
+		agentClient = ecsmetadata.NewClient(*daemonAgentURL)
+		agentClient.SchemaMonitor = client.SchemaMonitor
+		var err error
+		if dockerClient, err = ecsmetadata.NewDockerClient(*daemonDockerHost); err != nil {
+			logger.Error("Error creating Docker client", "err", err)
+			os.Exit(1)
+		}
+	}
+	collectors := []string{}
+	for name, enabled := range collectorFlags {
+		if *enabled {
+			collectors = append(collectors, name)
+		}
+	}
+	sort.Strings(collectors)
+	opts := ecscollector.Opts{Collectors: collectors}
+	if *taskLabels != "" {
+		opts.TaskLabels = strings.Split(*taskLabels, ",")
+	}
+	if *containerLabels != "" {
+		for _, s := range strings.Split(*containerLabels, ",") {
+			l, err := ecscollector.ParseContainerLabel(s)
+			if err != nil {
+				logger.Error("Error parsing container labels", "err", err)
+				os.Exit(1)
+			}
+			opts.ContainerLabels = append(opts.ContainerLabels, l)
+		}
+	}
+	if *includeContainers != "" {
+		re, err := compileAnchored(*includeContainers)
+		if err != nil {
+			logger.Error("Error parsing --containers.include", "err", err)
+			os.Exit(1)
+		}
+		opts.IncludeContainers = re
+	}
+	if *excludeContainers != "" {
+		re, err := compileAnchored(*excludeContainers)
+		if err != nil {
+			logger.Error("Error parsing --containers.exclude", "err", err)
+			os.Exit(1)
+		}
+		opts.ExcludeContainers = re
+	}
+	if *includeContainerTypes != "" {
+		opts.IncludeContainerTypes = strings.Split(*includeContainerTypes, ",")
+	}
+	if *excludeContainerTypes != "" {
+		opts.ExcludeContainerTypes = strings.Split(*excludeContainerTypes, ",")
+	}
+	opts.ExcludeSelf = *excludeSelf
+	opts.StoppedRetention = *stoppedRetention
+	opts.StatsTimestamps = *statsTimestamps
+	reloader := newReloader(*configFile, opts, client, logger)
+	reloader.agent, reloader.docker = agentClient, dockerClient
+	collector, p, cfg, err := reloader.load()
 	if err != nil {
-		logger.Error("Error creating client", "error", err)
+		logger.Error("Error creating collector", "err", err)
+		os.Exit(1)
+	}
+
+	metricsHandler := newMetricsHandler(collector, p, cfg.MetricRelabelConfigs, registry, promhttp.HandlerOpts{
+		ErrorLog:                            slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ErrorHandling:                       promhttp.ContinueOnError,
+		EnableOpenMetrics:                   true,
+		EnableOpenMetricsTextCreatedSamples: *createdSamples,
+	})
+	outputMetrics := output.NewMetrics()
+	registry.MustRegister(outputMetrics)
+	outputs := output.NewManager(metricsHandler, client, logger, outputMetrics)
+	if err := outputs.Apply(cfg.Outputs); err != nil {
+		logger.Error("Error creating outputs", "err", err)
 		os.Exit(1)
 	}
-	registry.MustRegister(ecscollector.NewCollector(client, logger))
+	reloader.applyCacheTTLs(cfg)
 
-	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	var handler http.Handler = metricsHandler
+	if *configFile != "" {
+		reloader.handler = metricsHandler
+		reloader.outputs = outputs
+		registry.MustRegister(reloader)
+		reloader.watchSignals()
+		http.Handle("/-/reload", reloader)
+	}
 	if !*disableExporterMetrics {
 		registry.MustRegister(
 			promcollectors.NewProcessCollector(promcollectors.ProcessCollectorOpts{}),
@@ -71,7 +278,12 @@ func main() {
 		handler = promhttp.InstrumentMetricHandler(registry, handler)
 	}
 
-	http.Handle(*metricsPath, handler)
+	drainer := newDrainer(*drainWindow, logger)
+	registry.MustRegister(drainer)
+	http.Handle(*metricsPath, drainer.wrap(handler))
+	if *sdPath != "" {
+		http.Handle(*sdPath, proxy.NewSDHandler(client, *sdHostAddress, logger))
+	}
 	if *metricsPath != "/" && *metricsPath != "" {
 		landingConfig := web.LandingConfig{
 			Name:        exporter,
@@ -84,6 +296,9 @@ func main() {
 				},
 			},
 		}
+		if *sdPath != "" {
+			landingConfig.Links = append(landingConfig.Links, web.LandingLinks{Address: *sdPath, Text: "Service discovery"})
+		}
 		landingPage, err := web.NewLandingPage(landingConfig)
 		if err != nil {
 			logger.Error("Error creating landing page", "err", err)
@@ -97,10 +312,32 @@ func main() {
 		fmt.Fprint(w, "ok")
 	})
 
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM, os.Interrupt)
 	srv := &http.Server{}
-	if err := web.ListenAndServe(srv, toolkitFlags, logger); err != nil {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- web.ListenAndServe(srv, toolkitFlags, logger)
+	}()
+	select {
+	case err := <-serveErr:
 		logger.Error("Error starting server", "err", err)
 		os.Exit(1)
+	case sig := <-term:
+		logger.Info("Received signal, shutting down", "signal", sig)
 	}
 
+	drainer.drain()
+	// Push the final metrics before the server stops serving them.
+	outputs.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Error shutting down server", "err", err)
+	}
+}
+
+// compileAnchored compiles a regexp matching whole strings only.
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
 }
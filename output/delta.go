@@ -0,0 +1,93 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// counterDeltas turns the cumulative values of counters into increases
+// since the previous push, for outputs which expect deltas. The values of a
+// push are staged until it succeeds, so that the increases of a failed push
+// are sent with the next one instead of being lost.
+type counterDeltas struct {
+	last   map[string]counterSample
+	staged map[string]counterSample
+}
+
+type counterSample struct {
+	value float64
+	time  time.Time
+}
+
+func newCounterDeltas() *counterDeltas {
+	return &counterDeltas{last: make(map[string]counterSample), staged: make(map[string]counterSample)}
+}
+
+// delta stages value as the value of the counter identified by key at now,
+// and returns its increase since the last committed push, along with the time
+// elapsed. It returns false if the counter wasn't part of that push. A counter
+// which went down was reset, e.g. by its container restarting, and
+// increased by its whole value since.
+func (d *counterDeltas) delta(key string, value float64, now time.Time) (float64, time.Duration, bool) {
+	d.staged[key] = counterSample{value: value, time: now}
+	prev, ok := d.last[key]
+	if !ok {
+		return 0, 0, false
+	}
+	delta := value - prev.value
+	if delta < 0 {
+		delta = value
+	}
+	return delta, now.Sub(prev.time), true
+}
+
+// commit makes the staged values those the next push's increases are
+// computed from, once the push succeeded. Counters which weren't staged are
+// forgotten, e.g. those of containers which are gone.
+func (d *counterDeltas) commit() {
+	d.last, d.staged = d.staged, make(map[string]counterSample)
+}
+
+// commitKeys commits the staged values of the counters identified by keys,
+// e.g. those sent by a push before it failed, and drops the others.
+func (d *counterDeltas) commitKeys(keys []string) {
+	for _, key := range keys {
+		if s, ok := d.staged[key]; ok {
+			d.last[key] = s
+		}
+	}
+	clear(d.staged)
+}
+
+// discard drops the staged values of a failed push.
+func (d *counterDeltas) discard() {
+	clear(d.staged)
+}
+
+// metricKey identifies a metric of the family name by its labels.
+func metricKey(name string, labels []*dto.LabelPair) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, lp := range labels {
+		b.WriteByte(0)
+		b.WriteString(lp.GetName())
+		b.WriteByte(0)
+		b.WriteString(lp.GetValue())
+	}
+	return b.String()
+}
@@ -0,0 +1,274 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/proto"
+)
+
+// DogStatsDConfig configures sending metrics to a DogStatsD server, such as
+// a Datadog agent sidecar, over UDP.
+type DogStatsDConfig struct {
+	PushConfig `yaml:",inline"`
+	// Address is the host:port the DogStatsD server listens on.
+	Address string `yaml:"address"`
+	// Prefix is prepended to metric names, e.g. "ecs.".
+	Prefix string `yaml:"prefix"`
+	// Tags are added to every metric, e.g. "env:prod".
+	Tags []string `yaml:"tags"`
+	// MaxPacketSize limits the size of the datagrams sent, which hold as many
+	// metrics as fit.
+	MaxPacketSize int `yaml:"max_packet_size"`
+}
+
+// DefaultDogStatsDConfig is the default DogStatsDConfig, which fields left out
+// of it in YAML default to. The interval matches the Datadog agent's flush
+// interval.
+var DefaultDogStatsDConfig = DogStatsDConfig{
+	PushConfig: PushConfig{
+		Interval: model.Duration(10 * time.Second),
+		Timeout:  DefaultPushConfig.Timeout,
+	},
+	Address:       "localhost:8125",
+	MaxPacketSize: 1432,
+}
+
+func (c *DogStatsDConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultDogStatsDConfig
+	type plain DogStatsDConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Address == "" {
+		return errors.New("dogstatsd output requires address")
+	}
+	if c.MaxPacketSize <= 0 {
+		return errors.New("dogstatsd output requires a positive max_packet_size")
+	}
+	return c.validate("dogstatsd")
+}
+
+var (
+	// dogStatsDName replaces the characters of metric names which are part
+	// of the DogStatsD syntax.
+	dogStatsDName = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_")
+	// dogStatsDTag replaces the characters of tags which are part of the
+	// DogStatsD syntax.
+	dogStatsDTag = strings.NewReplacer(",", "_", "|", "_", "\n", "_")
+)
+
+// dogStatsD sends gauges as DogStatsD gauges, and the increases of counters
+// since the previous push as counts, tagged with their labels. Summaries and
+// histograms are sent as the counts of their sums, counts and buckets, and
+// the gauges of their quantiles.
+type dogStatsD struct {
+	cfg DogStatsDConfig
+	now func() time.Time
+
+	mu     sync.Mutex
+	conn   net.Conn
+	deltas *counterDeltas
+}
+
+func newDogStatsD(cfg DogStatsDConfig) *dogStatsD {
+	return &dogStatsD{cfg: cfg, now: time.Now, deltas: newCounterDeltas()}
+}
+
+func (d *dogStatsD) Push(ctx context.Context, mfs []*dto.MetricFamily) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+
+	var lines []dogStatsDLine
+	gauge := func(name string, value float64, tags []string) {
+		// DogStatsD can't represent NaN, e.g. the quantiles of a summary
+		// without observations, or infinities.
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return
+		}
+		lines = append(lines, dogStatsDLine{text: d.line(name, value, "g", tags)})
+	}
+	count := func(name string, labels []*dto.LabelPair, value float64, tags []string) {
+		key := metricKey(name, labels)
+		delta, _, ok := d.deltas.delta(key, value, now)
+		if ok {
+			lines = append(lines, dogStatsDLine{text: d.line(name, delta, "c", tags), key: key})
+		}
+	}
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			tags := d.tags(m.Label)
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				count(name, m.Label, m.GetCounter().GetValue(), tags)
+			case dto.MetricType_GAUGE:
+				gauge(name, m.GetGauge().GetValue(), tags)
+			case dto.MetricType_UNTYPED:
+				gauge(name, m.GetUntyped().GetValue(), tags)
+			case dto.MetricType_SUMMARY:
+				for _, q := range m.GetSummary().GetQuantile() {
+					gauge(name, q.GetValue(), slices.Concat(tags, []string{"quantile:" + formatDogStatsD(q.GetQuantile())}))
+				}
+				count(name+"_sum", m.Label, m.GetSummary().GetSampleSum(), tags)
+				count(name+"_count", m.Label, float64(m.GetSummary().GetSampleCount()), tags)
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					le := formatDogStatsD(b.GetUpperBound())
+					labels := slices.Concat(m.Label, []*dto.LabelPair{{Name: proto.String("le"), Value: proto.String(le)}})
+					count(name+"_bucket", labels, float64(b.GetCumulativeCount()), slices.Concat(tags, []string{"le:" + le}))
+				}
+				count(name+"_sum", m.Label, h.GetSampleSum(), tags)
+				count(name+"_count", m.Label, float64(h.GetSampleCount()), tags)
+			}
+		}
+	}
+	if sent, err := d.send(ctx, lines); err != nil {
+		// The counts of the packets written before the error were
+		// delivered, and must not be sent again.
+		d.deltas.commitKeys(sent)
+		return err
+	}
+	d.deltas.commit()
+	return nil
+}
+
+// dogStatsDLine is a metric in the DogStatsD datagram format, with the key of
+// its counter if it is a count.
+type dogStatsDLine struct {
+	text string
+	key  string
+}
+
+// send writes lines to the DogStatsD server, in as few packets as fit them.
+// It returns the keys of the counts in the packets written.
+func (d *dogStatsD) send(ctx context.Context, lines []dogStatsDLine) ([]string, error) {
+	if d.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "udp", d.cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+		d.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := d.conn.SetWriteDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	var sent []string
+	for _, packet := range packets(lines, d.cfg.MaxPacketSize) {
+		if _, err := d.conn.Write(packet.data); err != nil {
+			return sent, err
+		}
+		sent = append(sent, packet.keys...)
+	}
+	return sent, nil
+}
+
+// Close closes the connection to the DogStatsD server.
+func (d *dogStatsD) Close(context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn == nil {
+		return nil
+	}
+	return d.conn.Close()
+}
+
+// tags returns the configured tags, followed by labels as tags.
+func (d *dogStatsD) tags(labels []*dto.LabelPair) []string {
+	tags := make([]string, 0, len(d.cfg.Tags)+len(labels))
+	for _, tag := range d.cfg.Tags {
+		tags = append(tags, dogStatsDTag.Replace(tag))
+	}
+	for _, lp := range labels {
+		tags = append(tags, dogStatsDTag.Replace(lp.GetName()+":"+lp.GetValue()))
+	}
+	return tags
+}
+
+// line formats a metric in the DogStatsD datagram format, e.g.
+// "ecs.container_memory_usage_bytes:1024|g|#container_name:app".
+func (d *dogStatsD) line(name string, value float64, typ string, tags []string) string {
+	var b strings.Builder
+	b.WriteString(dogStatsDName.Replace(d.cfg.Prefix + name))
+	b.WriteByte(':')
+	b.WriteString(formatDogStatsD(value))
+	b.WriteByte('|')
+	b.WriteString(typ)
+	if len(tags) > 0 {
+		b.WriteString("|#")
+		b.WriteString(strings.Join(tags, ","))
+	}
+	return b.String()
+}
+
+// formatDogStatsD formats v without an exponent, which DogStatsD doesn't
+// parse.
+func formatDogStatsD(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// packet is a datagram of newline separated lines, with the keys of the
+// counts in it.
+type packet struct {
+	data []byte
+	keys []string
+}
+
+// packets joins lines into packets of at most size bytes. A line longer than
+// size is sent in a packet of its own.
+func packets(lines []dogStatsDLine, size int) []packet {
+	var (
+		packets []packet
+		p       packet
+	)
+	for _, line := range lines {
+		if len(p.data) > 0 && len(p.data)+1+len(line.text) > size {
+			packets = append(packets, p)
+			p = packet{}
+		}
+		if len(p.data) > 0 {
+			p.data = append(p.data, '\n')
+		}
+		p.data = append(p.data, line.text...)
+		if line.key != "" {
+			p.keys = append(p.keys, line.key)
+		}
+	}
+	if len(p.data) > 0 {
+		packets = append(packets, p)
+	}
+	return packets
+}
@@ -0,0 +1,237 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"math"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+)
+
+// readPackets reads the datagrams sent to conn until none arrives for a
+// while.
+func readPackets(t *testing.T, conn net.PacketConn) []string {
+	t.Helper()
+	var packets []string
+	buf := make([]byte, 65536)
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+		n, _, err := conn.ReadFrom(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return packets
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+func TestDogStatsD(t *testing.T) {
+	server := fixtureServer(t)
+	collector, err := ecscollector.NewCollector(server.Client(), slog.Default(), ecscollector.Opts{
+		Collectors: []string{"container_cpu", "container_memory"},
+		TaskLabels: []string{"cluster_name"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cfg := DefaultDogStatsDConfig
+	cfg.Address = conn.LocalAddr().String()
+	cfg.Prefix = "ecs."
+	cfg.Tags = []string{"env:test"}
+	cfg.MaxPacketSize = 512
+	d := newDogStatsD(cfg)
+	d.now = server.Now
+	defer d.Close(context.Background())
+
+	push := func() ([]*dto.MetricFamily, []string) {
+		t.Helper()
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Push(context.Background(), mfs); err != nil {
+			t.Fatal(err)
+		}
+		var lines []string
+		for _, packet := range readPackets(t, conn) {
+			if len(packet) > cfg.MaxPacketSize {
+				t.Errorf("got packet of %d bytes, want at most %d", len(packet), cfg.MaxPacketSize)
+			}
+			lines = append(lines, strings.Split(packet, "\n")...)
+		}
+		return mfs, lines
+	}
+	find := func(lines []string, prefix, suffix string) string {
+		t.Helper()
+		for _, line := range lines {
+			if strings.HasPrefix(line, prefix) && strings.HasSuffix(line, suffix) {
+				return strings.TrimSuffix(strings.TrimPrefix(line, prefix), suffix)
+			}
+		}
+		t.Fatalf("no line %q...%q in %q", prefix, suffix, lines)
+		return ""
+	}
+	tags := "|#env:test,cluster_name:prom-ecs-exporter-sandbox,container_name:prometheus"
+
+	before, lines := push()
+	if got, want := find(lines, "ecs.ecs_container_memory_usage_bytes:", "|g"+tags), "127934464"; got != want {
+		t.Errorf("got memory usage %s, want %s", got, want)
+	}
+	if slices.ContainsFunc(lines, func(line string) bool { return strings.Contains(line, "|c") }) {
+		t.Errorf("got counts on the first push, want them only once there is a previous value: %q", lines)
+	}
+
+	server.Advance(time.Minute)
+	after, lines := push()
+	cpu := func(mfs []*dto.MetricFamily) float64 {
+		return counterValue(mfs, "ecs_container_cpu_usage_seconds_total", "prometheus")
+	}
+	want := cpu(after) - cpu(before)
+	got, err := strconv.ParseFloat(find(lines, "ecs.ecs_container_cpu_usage_seconds_total:", "|c"+tags), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got cpu usage count %v, want %v", got, want)
+	}
+
+	// The increase of a failed push is sent with the next one.
+	server.Advance(time.Minute)
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.conn.Close()
+	if err := d.Push(context.Background(), mfs); err == nil {
+		t.Fatal("expected an error pushing on a closed connection")
+	}
+	d.conn = nil
+	server.Advance(time.Minute)
+	last, lines := push()
+	want = cpu(last) - cpu(after)
+	got, err = strconv.ParseFloat(find(lines, "ecs.ecs_container_cpu_usage_seconds_total:", "|c"+tags), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got cpu usage count %v after a failed push, want %v", got, want)
+	}
+
+	// Of a push failing after its first packet, only the counts of the other
+	// packets are sent again with the next push. A reference output, which
+	// pushes without failing, gets the increases since the last push.
+	refConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer refConn.Close()
+	refCfg := cfg
+	refCfg.Address = refConn.LocalAddr().String()
+	ref := newDogStatsD(refCfg)
+	defer ref.Close(context.Background())
+	if err := ref.Push(context.Background(), last); err != nil {
+		t.Fatal(err)
+	}
+	readPackets(t, refConn)
+
+	server.Advance(time.Minute)
+	mfs, err = registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.conn = &failingConn{Conn: d.conn, writes: 1}
+	if err := d.Push(context.Background(), mfs); err == nil {
+		t.Fatal("expected an error pushing after the first packet")
+	}
+	gotCounts := counts(readPackets(t, conn))
+	if len(gotCounts) == 0 {
+		t.Fatal("got no counts in the first packet")
+	}
+	d.conn = d.conn.(*failingConn).Conn
+	server.Advance(time.Minute)
+	final, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Push(context.Background(), final); err != nil {
+		t.Fatal(err)
+	}
+	for line, v := range counts(readPackets(t, conn)) {
+		gotCounts[line] += v
+	}
+	if err := ref.Push(context.Background(), final); err != nil {
+		t.Fatal(err)
+	}
+	wantCounts := counts(readPackets(t, refConn))
+	if !maps.EqualFunc(gotCounts, wantCounts, func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }) {
+		t.Errorf("got counts %v across a partially failed push, want %v", gotCounts, wantCounts)
+	}
+}
+
+// failingConn fails the writes after the first few.
+type failingConn struct {
+	net.Conn
+	writes int
+}
+
+func (c *failingConn) Write(b []byte) (int, error) {
+	if c.writes == 0 {
+		return 0, errors.New("write failed")
+	}
+	c.writes--
+	return c.Conn.Write(b)
+}
+
+// counts returns the values of the counts in packets, by name and tags.
+func counts(packets []string) map[string]float64 {
+	counts := make(map[string]float64)
+	for _, packet := range packets {
+		for _, line := range strings.Split(packet, "\n") {
+			name, rest, _ := strings.Cut(line, ":")
+			value, tags, _ := strings.Cut(rest, "|")
+			if !strings.HasPrefix(tags, "c") {
+				continue
+			}
+			v, _ := strconv.ParseFloat(value, 64)
+			counts[name+"|"+tags] += v
+		}
+	}
+	return counts
+}
@@ -0,0 +1,291 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// EMF dimensions.
+const (
+	emfClusterName          = "ClusterName"
+	emfServiceName          = "ServiceName"
+	emfTaskDefinitionFamily = "TaskDefinitionFamily"
+	emfTaskID               = "TaskId"
+	emfContainerName        = "ContainerName"
+)
+
+var emfDimensions = []string{emfClusterName, emfServiceName, emfTaskDefinitionFamily, emfTaskID, emfContainerName}
+
+// EMFConfig configures writing metrics to stdout in the CloudWatch Embedded
+// Metric Format, for the awslogs log driver to send to CloudWatch Logs.
+type EMFConfig struct {
+	PushConfig `yaml:",inline"`
+	// Namespace is the CloudWatch namespace of the metrics.
+	Namespace string `yaml:"namespace"`
+	// Dimensions are the sets of dimensions to aggregate metrics by, among
+	// ClusterName, ServiceName, TaskDefinitionFamily, TaskId and
+	// ContainerName. Sets with ContainerName only apply to container
+	// metrics.
+	Dimensions [][]string `yaml:"dimensions"`
+}
+
+// DefaultEMFConfig is the default EMFConfig, which fields left out of it in
+// YAML default to.
+var DefaultEMFConfig = EMFConfig{
+	PushConfig: DefaultPushConfig,
+	// Not ECS/ContainerInsights, so as not to add to the metrics of
+	// Container Insights, if it's enabled too.
+	Namespace: "ecs_exporter",
+	Dimensions: [][]string{
+		{emfClusterName},
+		{emfClusterName, emfTaskDefinitionFamily},
+		{emfClusterName, emfTaskDefinitionFamily, emfContainerName},
+	},
+}
+
+func (c *EMFConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultEMFConfig
+	type plain EMFConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Namespace == "" {
+		return errors.New("emf output requires namespace")
+	}
+	if len(c.Dimensions) == 0 {
+		return errors.New("emf output requires at least one dimension set")
+	}
+	for _, set := range c.Dimensions {
+		for _, dim := range set {
+			if !slices.Contains(emfDimensions, dim) {
+				return fmt.Errorf("unknown emf dimension %q, must be one of %v", dim, emfDimensions)
+			}
+		}
+	}
+	return c.validate("emf")
+}
+
+// emfMetric maps a metric family to a Container Insights metric.
+type emfMetric struct {
+	name, unit string
+	// scale converts the value to unit.
+	scale float64
+	// perSecond makes counters rates, rather than increases.
+	perSecond bool
+	// task sums container metrics into the task's metric of the same
+	// name.
+	task bool
+}
+
+// emfMetrics are the metrics written, named and scaled like those of
+// Container Insights. Counters are turned into increases or rates since the
+// previous push, and container metrics are summed over their other labels.
+var emfMetrics = map[string]emfMetric{
+	"ecs_container_cpu_usage_seconds_total":      {name: "CpuUtilized", unit: "None", scale: 1024, perSecond: true, task: true},
+	"ecs_container_memory_usage_bytes":           {name: "MemoryUtilized", unit: "Megabytes", scale: 1.0 / (1 << 20), task: true},
+	"ecs_container_memory_limit_bytes":           {name: "MemoryReserved", unit: "Megabytes", scale: 1.0 / (1 << 20)},
+	"ecs_container_blkio_read_bytes_total":       {name: "StorageReadBytes", unit: "Bytes", scale: 1, task: true},
+	"ecs_container_blkio_write_bytes_total":      {name: "StorageWriteBytes", unit: "Bytes", scale: 1, task: true},
+	"ecs_task_cpu_limit_vcpus":                   {name: "CpuReserved", unit: "None", scale: 1024},
+	"ecs_task_memory_limit_bytes":                {name: "MemoryReserved", unit: "Megabytes", scale: 1.0 / (1 << 20)},
+	"ecs_task_ephemeral_storage_used_bytes":      {name: "EphemeralStorageUtilized", unit: "Gigabytes", scale: 1.0 / (1 << 30)},
+	"ecs_task_ephemeral_storage_allocated_bytes": {name: "EphemeralStorageReserved", unit: "Gigabytes", scale: 1.0 / (1 << 30)},
+	"ecs_network_receive_bytes_total":            {name: "NetworkRxBytes", unit: "Bytes/Second", scale: 1, perSecond: true},
+	"ecs_network_transmit_bytes_total":           {name: "NetworkTxBytes", unit: "Bytes/Second", scale: 1, perSecond: true},
+}
+
+// emf writes Container Insights compatible EMF records, one for the task and
+// one for each container, as JSON lines.
+type emf struct {
+	cfg    EMFConfig
+	client *ecsmetadata.Client
+	out    io.Writer
+	now    func() time.Time
+
+	mu     sync.Mutex
+	task   *tmdsv4.TaskResponse
+	deltas *counterDeltas
+}
+
+func newEMF(cfg EMFConfig, client *ecsmetadata.Client, out io.Writer) *emf {
+	return &emf{cfg: cfg, client: client, out: out, now: time.Now, deltas: newCounterDeltas()}
+}
+
+// emfRecord holds the metrics of the task, or of one of its containers.
+type emfRecord struct {
+	container string
+	values    map[string]float64
+	units     map[string]string
+}
+
+func (e *emf) Push(ctx context.Context, mfs []*dto.MetricFamily) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.task == nil {
+		task, err := e.client.RetrieveTaskMetadata(ctx)
+		if err != nil {
+			return err
+		}
+		e.task = task
+	}
+	now := e.now()
+
+	taskRecord := &emfRecord{values: map[string]float64{}, units: map[string]string{}}
+	containers := map[string]*emfRecord{}
+	add := func(r *emfRecord, metric emfMetric, value float64) {
+		r.values[metric.name] += value
+		r.units[metric.name] = metric.unit
+	}
+	for _, mf := range mfs {
+		metric, ok := emfMetrics[mf.GetName()]
+		if !ok {
+			continue
+		}
+		for _, m := range mf.Metric {
+			value := m.GetGauge().GetValue()
+			if mf.GetType() == dto.MetricType_COUNTER {
+				// Rates are computed from when the stats were read,
+				// if the metric says, as they may have been cached.
+				at := now
+				if m.TimestampMs != nil {
+					at = time.UnixMilli(m.GetTimestampMs())
+				}
+				delta, elapsed, ok := e.deltas.delta(metricKey(mf.GetName(), m.Label), m.GetCounter().GetValue(), at)
+				if !ok || elapsed <= 0 {
+					continue
+				}
+				value = delta
+				if metric.perSecond {
+					value /= elapsed.Seconds()
+				}
+			}
+			value *= metric.scale
+
+			container := findLabel(m.Label, "container_name")
+			if container == "" {
+				add(taskRecord, metric, value)
+				continue
+			}
+			r, ok := containers[container]
+			if !ok {
+				r = &emfRecord{container: container, values: map[string]float64{}, units: map[string]string{}}
+				containers[container] = r
+			}
+			add(r, metric, value)
+			if metric.task {
+				add(taskRecord, metric, value)
+			}
+		}
+	}
+	records := []*emfRecord{taskRecord}
+	for _, name := range slices.Sorted(maps.Keys(containers)) {
+		records = append(records, containers[name])
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if len(r.values) == 0 {
+			continue
+		}
+		if err := enc.Encode(e.record(r, now)); err != nil {
+			e.deltas.discard()
+			return err
+		}
+	}
+	if _, err := e.out.Write(buf.Bytes()); err != nil {
+		e.deltas.discard()
+		return err
+	}
+	e.deltas.commit()
+	return nil
+}
+
+// record returns the EMF document for r.
+func (e *emf) record(r *emfRecord, now time.Time) map[string]interface{} {
+	dims := map[string]string{
+		emfClusterName:          ecscollector.TaskLabelValue(e.task, "cluster_name"),
+		emfServiceName:          e.task.ServiceName,
+		emfTaskDefinitionFamily: e.task.Family,
+		emfTaskID:               ecscollector.TaskLabelValue(e.task, "task_id"),
+		emfContainerName:        r.container,
+	}
+	doc := map[string]interface{}{
+		"Type":                   "Task",
+		"Version":                "0",
+		emfClusterName:           dims[emfClusterName],
+		emfTaskDefinitionFamily:  dims[emfTaskDefinitionFamily],
+		"TaskDefinitionRevision": e.task.Revision,
+		emfTaskID:                dims[emfTaskID],
+		"LaunchType":             e.task.LaunchType,
+	}
+	if e.task.ServiceName != "" {
+		doc[emfServiceName] = e.task.ServiceName
+	}
+	if r.container != "" {
+		doc["Type"] = "Container"
+		doc[emfContainerName] = r.container
+	}
+
+	// Leave out the dimension sets which don't apply, e.g. ServiceName to
+	// tasks not part of a service. Container metrics are only aggregated by
+	// sets with ContainerName, so as not to be counted with task metrics.
+	dimensionSets := [][]string{}
+	for _, set := range e.cfg.Dimensions {
+		if slices.Contains(set, emfContainerName) != (r.container != "") {
+			continue
+		}
+		if !slices.ContainsFunc(set, func(dim string) bool { return dims[dim] == "" }) {
+			dimensionSets = append(dimensionSets, set)
+		}
+	}
+	var metrics []map[string]string
+	for _, name := range slices.Sorted(maps.Keys(r.values)) {
+		doc[name] = r.values[name]
+		metrics = append(metrics, map[string]string{"Name": name, "Unit": r.units[name]})
+	}
+	doc["_aws"] = map[string]interface{}{
+		"Timestamp": now.UnixMilli(),
+		"CloudWatchMetrics": []map[string]interface{}{{
+			"Namespace":  e.cfg.Namespace,
+			"Dimensions": dimensionSets,
+			"Metrics":    metrics,
+		}},
+	}
+	return doc
+}
+
+func findLabel(labels []*dto.LabelPair, name string) string {
+	for _, lp := range labels {
+		if lp.GetName() == name {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
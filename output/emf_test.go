@@ -0,0 +1,220 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+)
+
+func counterValue(mfs []*dto.MetricFamily, name, container string) float64 {
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.Metric {
+			if findLabel(m.Label, "container_name") == container {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return math.NaN()
+}
+
+func TestEMF(t *testing.T) {
+	server := fixtureServer(t)
+	collector, err := ecscollector.NewCollector(server.Client(), slog.Default(), ecscollector.Opts{
+		Collectors: []string{"container_cpu", "container_memory", "network", "task"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	var out bytes.Buffer
+	e := newEMF(DefaultEMFConfig, server.Client(), &out)
+	e.now = server.Now
+	push := func() ([]*dto.MetricFamily, map[string]map[string]interface{}) {
+		t.Helper()
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Reset()
+		if err := e.Push(context.Background(), mfs); err != nil {
+			t.Fatal(err)
+		}
+		records := make(map[string]map[string]interface{})
+		dec := json.NewDecoder(&out)
+		for dec.More() {
+			var record map[string]interface{}
+			if err := dec.Decode(&record); err != nil {
+				t.Fatal(err)
+			}
+			key, _ := record["ContainerName"].(string)
+			records[key] = record
+		}
+		return mfs, records
+	}
+
+	before, records := push()
+	if _, ok := records["prometheus"]["CpuUtilized"]; ok {
+		t.Error("got CpuUtilized on the first push, want it only once there is a previous value")
+	}
+	if got, want := records["prometheus"]["MemoryUtilized"], 1.27934464e+08/(1<<20); got != want {
+		t.Errorf("got MemoryUtilized %v, want %v", got, want)
+	}
+
+	server.Advance(time.Minute)
+	after, records := push()
+	cpu := func(mfs []*dto.MetricFamily) float64 {
+		return counterValue(mfs, "ecs_container_cpu_usage_seconds_total", "prometheus")
+	}
+	if got, want := records["prometheus"]["CpuUtilized"], (cpu(after)-cpu(before))/60*1024; math.Abs(got.(float64)-want) > 1e-9 {
+		t.Errorf("got CpuUtilized %v, want %v", got, want)
+	}
+	task := records[""]
+	if task["Type"] != "Task" || task["ClusterName"] != "prom-ecs-exporter-sandbox" || task["TaskDefinitionFamily"] != "prom-ecs-exporter-sandbox-main-fargate" {
+		t.Errorf("got task record %v", task)
+	}
+	if _, ok := task["NetworkRxBytes"]; !ok {
+		t.Error("missing NetworkRxBytes in task record")
+	}
+	// Task-level records leave out dimension sets with ContainerName.
+	directive := task["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	want := []interface{}{[]interface{}{"ClusterName"}, []interface{}{"ClusterName", "TaskDefinitionFamily"}}
+	if !reflect.DeepEqual(directive["Dimensions"], want) || directive["Namespace"] != "ecs_exporter" {
+		t.Errorf("got metric directive %v, want dimensions %v", directive, want)
+	}
+	// Container-level records only keep those with ContainerName.
+	directive = records["prometheus"]["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	want = []interface{}{[]interface{}{"ClusterName", "TaskDefinitionFamily", "ContainerName"}}
+	if !reflect.DeepEqual(directive["Dimensions"], want) {
+		t.Errorf("got container dimensions %v, want %v", directive["Dimensions"], want)
+	}
+
+	// The increase of a failed write is included in the next record, as
+	// rates are computed from the counters of the last successful write.
+	server.Advance(time.Minute)
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.out = failingWriter{}
+	if err := e.Push(context.Background(), mfs); err == nil {
+		t.Fatal("expected an error writing the records")
+	}
+	for key, sample := range e.deltas.last {
+		if strings.HasPrefix(key, "ecs_container_cpu_usage_seconds_total\x00") && strings.Contains(key, "\x00prometheus") && sample.value != cpu(after) {
+			t.Errorf("got last CPU usage %v after a failed write, want %v from the previous write", sample.value, cpu(after))
+		}
+	}
+	e.out = &out
+	server.Advance(time.Minute)
+	last, records := push()
+	if got, want := records["prometheus"]["CpuUtilized"], (cpu(last)-cpu(after))/120*1024; math.Abs(got.(float64)-want) > 1e-9 {
+		t.Errorf("got CpuUtilized %v after a failed write, want %v", got, want)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestEMFStatsTimestamps(t *testing.T) {
+	server := fixtureServer(t)
+	collector, err := ecscollector.NewCollector(server.Client(), slog.Default(), ecscollector.Opts{
+		Collectors:      []string{"container_cpu"},
+		StatsTimestamps: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	var out bytes.Buffer
+	e := newEMF(DefaultEMFConfig, server.Client(), &out)
+	// Pushes happen two minutes apart, but the stats they see, e.g. cached,
+	// were read one minute apart.
+	pushTime := server.Now()
+	e.now = func() time.Time { return pushTime }
+	push := func() []*dto.MetricFamily {
+		t.Helper()
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Reset()
+		if err := e.Push(context.Background(), mfs); err != nil {
+			t.Fatal(err)
+		}
+		return mfs
+	}
+	before := push()
+	server.Advance(time.Minute)
+	pushTime = pushTime.Add(2 * time.Minute)
+	after := push()
+
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record["ContainerName"] != "prometheus" {
+			continue
+		}
+		cpu := func(mfs []*dto.MetricFamily) (float64, time.Time) {
+			for _, mf := range mfs {
+				if mf.GetName() != "ecs_container_cpu_usage_seconds_total" {
+					continue
+				}
+				for _, m := range mf.Metric {
+					if findLabel(m.Label, "container_name") == "prometheus" {
+						return m.GetCounter().GetValue(), time.UnixMilli(m.GetTimestampMs())
+					}
+				}
+			}
+			t.Fatal("missing CPU usage of container prometheus")
+			return 0, time.Time{}
+		}
+		v0, t0 := cpu(before)
+		v1, t1 := cpu(after)
+		if elapsed := t1.Sub(t0); elapsed > 2*time.Minute-time.Second {
+			t.Fatalf("got %v between the stats, want less than between the pushes", elapsed)
+		}
+		if got, want := record["CpuUtilized"], (v1-v0)/t1.Sub(t0).Seconds()*1024; math.Abs(got.(float64)-want) > 1e-9 {
+			t.Errorf("got CpuUtilized %v, want %v over the time between the stats", got, want)
+		}
+		return
+	}
+	t.Error("missing record for container prometheus")
+}
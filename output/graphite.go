@@ -0,0 +1,125 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// GraphiteConfig configures sending metrics to Graphite, or anything
+// accepting its plaintext protocol, over TCP.
+type GraphiteConfig struct {
+	PushConfig `yaml:",inline"`
+	TagsConfig `yaml:",inline"`
+	// Address is the host:port of the plaintext receiver, e.g.
+	// carbon:2003.
+	Address string `yaml:"address"`
+	// Tagged sends tags with Graphite's tag syntax, e.g.
+	// "ecs_container_memory_usage_bytes;container_name=app". Otherwise, tag
+	// values are appended to the path, e.g.
+	// "ecs_container_memory_usage_bytes.app".
+	Tagged bool `yaml:"tagged"`
+}
+
+// DefaultGraphiteConfig is the default GraphiteConfig, which fields left out
+// of it in YAML default to.
+var DefaultGraphiteConfig = GraphiteConfig{
+	PushConfig: DefaultPushConfig,
+	Tagged:     true,
+}
+
+func (c *GraphiteConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultGraphiteConfig
+	type plain GraphiteConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Address == "" {
+		return errors.New("graphite output requires address")
+	}
+	return c.validate("graphite")
+}
+
+var (
+	// graphitePath replaces the characters of paths which are part of the
+	// plaintext protocol, or of Graphite's tag syntax.
+	graphitePath = strings.NewReplacer(" ", "_", ";", "_", "\n", "_")
+	// graphiteNode additionally replaces the dots of tag values appended to
+	// paths, which would make them span several nodes.
+	graphiteNode = strings.NewReplacer(" ", "_", ";", "_", "\n", "_", ".", "_")
+	// graphiteTag replaces the characters of tags which are part of the
+	// plaintext protocol, or of Graphite's tag syntax.
+	graphiteTag = strings.NewReplacer(" ", "_", ";", "_", "\n", "_", "=", "_", "~", "_", "!", "_", "^", "_")
+)
+
+// graphite sends each series as a line of the plaintext protocol, e.g.
+// "ecs_container_memory_usage_bytes;container_name=app 1024 1700000000",
+// over a new connection for each push.
+type graphite struct {
+	cfg GraphiteConfig
+}
+
+func newGraphite(cfg GraphiteConfig) *graphite {
+	return &graphite{cfg: cfg}
+}
+
+func (g *graphite) Push(ctx context.Context, mfs []*dto.MetricFamily) error {
+	var buf bytes.Buffer
+	for _, s := range seriesOf(mfs, g.cfg.Tags, time.Now().UnixMilli()) {
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+		name, tags := g.cfg.tagged(s)
+		buf.WriteString(graphitePath.Replace(name))
+		for _, t := range tags {
+			if g.cfg.Tagged {
+				buf.WriteByte(';')
+				buf.WriteString(graphiteTag.Replace(t.name))
+				buf.WriteByte('=')
+				buf.WriteString(graphiteTag.Replace(t.value))
+			} else {
+				buf.WriteByte('.')
+				buf.WriteString(graphiteNode.Replace(t.value))
+			}
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(s.timestampMs/1000, 10))
+		buf.WriteByte('\n')
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", g.cfg.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	_, err = buf.WriteTo(conn)
+	return err
+}
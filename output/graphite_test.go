@@ -0,0 +1,74 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestGraphite(t *testing.T) {
+	server := fixtureServer(t)
+	mfs, err := fixtureGatherer(t, server).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// receive pushes with cfg and returns what was received.
+	receive := func(cfg GraphiteConfig) string {
+		t.Helper()
+		received := make(chan string)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				received <- err.Error()
+				return
+			}
+			defer conn.Close()
+			b, _ := io.ReadAll(conn)
+			received <- string(b)
+		}()
+		if err := newGraphite(cfg).Push(context.Background(), mfs); err != nil {
+			t.Fatal(err)
+		}
+		return <-received
+	}
+	hasLine := func(received, prefix string) {
+		t.Helper()
+		for _, line := range strings.Split(received, "\n") {
+			if strings.HasPrefix(line, prefix) {
+				return
+			}
+		}
+		t.Errorf("no line %q... in:\n%s", prefix, received)
+	}
+
+	cfg := DefaultGraphiteConfig
+	cfg.Address = l.Addr().String()
+	cfg.Prefix = "ecs."
+	cfg.Tags = map[string]string{"env": "test"}
+	cfg.TagMapping = map[string]string{"task_arn": ""}
+	hasLine(receive(cfg), "ecs.ecs_container_cpu_usage_seconds_total;container_name=prometheus;env=test 0.9324394920000001 ")
+
+	cfg.Tagged = false
+	cfg.Tags = map[string]string{"env": "test.1"}
+	hasLine(receive(cfg), "ecs.ecs_container_cpu_usage_seconds_total.prometheus.test_1 0.9324394920000001 ")
+}
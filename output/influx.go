@@ -0,0 +1,123 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+)
+
+// InfluxConfig configures writing metrics to InfluxDB, or Telegraf's
+// influxdb_listener, in the line protocol.
+type InfluxConfig struct {
+	PushConfig `yaml:",inline"`
+	TagsConfig `yaml:",inline"`
+	// URL is the write endpoint, e.g.
+	// http://influxdb:8086/api/v2/write?org=ops&bucket=ecs for InfluxDB 2, or
+	// http://influxdb:8086/write?db=ecs for InfluxDB 1.
+	URL string `yaml:"url"`
+	// Headers are sent with every request, e.g. Authorization: Token ....
+	Headers map[string]string `yaml:"headers"`
+}
+
+// DefaultInfluxConfig is the default InfluxConfig, which fields left out of
+// it in YAML default to.
+var DefaultInfluxConfig = InfluxConfig{
+	PushConfig: DefaultPushConfig,
+}
+
+func (c *InfluxConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultInfluxConfig
+	type plain InfluxConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.URL == "" {
+		return errors.New("influx output requires url")
+	}
+	return c.validate("influx")
+}
+
+var (
+	// influxMeasurement escapes measurements in the line protocol.
+	influxMeasurement = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	// influxTag escapes tag keys and values in the line protocol.
+	influxTag = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// influx writes each series as a measurement of its name with a value field,
+// e.g. "ecs_container_memory_usage_bytes,container_name=app value=1024
+// 1700000000000000000".
+type influx struct {
+	cfg    InfluxConfig
+	client *http.Client
+}
+
+func newInflux(cfg InfluxConfig) *influx {
+	return &influx{cfg: cfg, client: &http.Client{}}
+}
+
+func (i *influx) Push(ctx context.Context, mfs []*dto.MetricFamily) error {
+	var buf bytes.Buffer
+	for _, s := range seriesOf(mfs, i.cfg.Tags, time.Now().UnixMilli()) {
+		// The line protocol can't represent NaN or infinities.
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+		name, tags := i.cfg.tagged(s)
+		buf.WriteString(influxMeasurement.Replace(name))
+		for _, t := range tags {
+			buf.WriteByte(',')
+			buf.WriteString(influxTag.Replace(t.name))
+			buf.WriteByte('=')
+			buf.WriteString(influxTag.Replace(t.value))
+		}
+		buf.WriteString(" value=")
+		buf.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(s.timestampMs*int64(time.Millisecond), 10))
+		buf.WriteByte('\n')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.cfg.URL, &buf)
+	if err != nil {
+		return err
+	}
+	for name, value := range i.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "ecs_exporter/"+version.Version)
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
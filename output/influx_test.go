@@ -0,0 +1,73 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInflux(t *testing.T) {
+	server := fixtureServer(t)
+	mfs, err := fixtureGatherer(t, server).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body, auth string
+	influxServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("db") != "ecs" {
+			http.Error(w, "database not found", http.StatusNotFound)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		body, auth = string(b), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer influxServer.Close()
+
+	cfg := DefaultInfluxConfig
+	cfg.URL = influxServer.URL + "/write?db=ecs"
+	cfg.Headers = map[string]string{"Authorization": "Token secret"}
+	cfg.Prefix = "ecs."
+	cfg.Tags = map[string]string{"env": "test env"}
+	cfg.TagMapping = map[string]string{"container_name": "container", "task_arn": ""}
+	if err := newInflux(cfg).Push(context.Background(), mfs); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Token secret" {
+		t.Errorf("got Authorization %q, want the configured header", auth)
+	}
+	want := `ecs.ecs_container_cpu_usage_seconds_total,container=prometheus,env=test\ env value=0.9324394920000001 `
+	found := false
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		if strings.HasPrefix(line, want) {
+			found = true
+		}
+		if strings.Contains(line, "task_arn") {
+			t.Errorf("got line %q, want task_arn dropped by the tag mapping", line)
+		}
+	}
+	if !found {
+		t.Errorf("no line %q... in:\n%s", want, body)
+	}
+
+	cfg.URL = influxServer.URL + "/write?db=nope"
+	if err := newInflux(cfg).Push(context.Background(), mfs); err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Errorf("got error %v, want the server's error", err)
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// OTLP protocols.
+const (
+	otlpHTTP = "http/protobuf"
+	otlpGRPC = "grpc"
+)
+
+// OTLPConfig configures exporting metrics with the OpenTelemetry protocol.
+type OTLPConfig struct {
+	PushConfig `yaml:",inline"`
+	// Endpoint is the base URL of the OTLP receiver, e.g.
+	// http://otel-collector:4318 for OTLP/HTTP, to which /v1/metrics is
+	// appended, or http://otel-collector:4317 for OTLP/gRPC, which is sent
+	// without TLS to http:// endpoints.
+	Endpoint string `yaml:"endpoint"`
+	// Protocol is either http/protobuf or grpc.
+	Protocol string `yaml:"protocol"`
+	// Headers are sent with every request, or as gRPC metadata.
+	Headers map[string]string `yaml:"headers"`
+	// ResourceAttributes are added to the attributes of the task resource,
+	// e.g. service.name.
+	ResourceAttributes map[string]string `yaml:"resource_attributes"`
+}
+
+// DefaultOTLPConfig is the default OTLPConfig, which fields left out of it in
+// YAML default to.
+var DefaultOTLPConfig = OTLPConfig{
+	PushConfig: DefaultPushConfig,
+	Protocol:   otlpHTTP,
+}
+
+func (c *OTLPConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultOTLPConfig
+	type plain OTLPConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Endpoint == "" {
+		return errors.New("otlp output requires endpoint")
+	}
+	if c.Protocol != otlpHTTP && c.Protocol != otlpGRPC {
+		return fmt.Errorf("unknown otlp protocol %q, must be %s or %s", c.Protocol, otlpHTTP, otlpGRPC)
+	}
+	return c.validate("otlp")
+}
+
+// otlp exports metrics to an OTLP receiver, as a resource for the task with
+// the attributes of the aws.ecs.* semantic conventions. Counters are
+// cumulative sums, and the container_name label becomes the container.name
+// attribute.
+type otlp struct {
+	cfg        OTLPConfig
+	url        string
+	client     *ecsmetadata.Client
+	httpClient *http.Client
+	// conn and grpcClient are set for OTLP/gRPC.
+	conn       *grpc.ClientConn
+	grpcClient colmetricspb.MetricsServiceClient
+	// start is the start time of cumulative metrics without a created
+	// timestamp.
+	start time.Time
+
+	mu       sync.Mutex
+	resource []*commonpb.KeyValue
+}
+
+func newOTLP(cfg OTLPConfig, client *ecsmetadata.Client) (*otlp, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	o := &otlp{
+		cfg:    cfg,
+		client: client,
+		start:  time.Now(),
+	}
+	if cfg.Protocol == otlpGRPC {
+		creds := credentials.NewTLS(&tls.Config{})
+		if u.Scheme == "http" {
+			creds = insecure.NewCredentials()
+		}
+		// The connection is only established on the first push.
+		o.conn, err = grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds), grpc.WithUserAgent("ecs_exporter/"+version.Version))
+		if err != nil {
+			return nil, err
+		}
+		o.grpcClient = colmetricspb.NewMetricsServiceClient(o.conn)
+		return o, nil
+	}
+	o.url = strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/metrics"
+	o.httpClient = &http.Client{}
+	return o, nil
+}
+
+func (o *otlp) Push(ctx context.Context, mfs []*dto.MetricFamily) error {
+	resource, err := o.resourceAttributes(ctx)
+	if err != nil {
+		return err
+	}
+	req := exportRequest(mfs, resource, uint64(o.start.UnixNano()), uint64(time.Now().UnixNano()))
+	if o.cfg.Protocol == otlpGRPC {
+		return o.exportGRPC(ctx, req)
+	}
+	return o.exportHTTP(ctx, req)
+}
+
+// Close closes the gRPC connection.
+func (o *otlp) Close(ctx context.Context) error {
+	if o.conn == nil {
+		return nil
+	}
+	return o.conn.Close()
+}
+
+func (o *otlp) exportHTTP(ctx context.Context, export *colmetricspb.ExportMetricsServiceRequest) error {
+	body, err := proto.Marshal(export)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range o.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "ecs_exporter/"+version.Version)
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (o *otlp) exportGRPC(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	if len(o.cfg.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.cfg.Headers))
+	}
+	_, err := o.grpcClient.Export(ctx, req)
+	return err
+}
+
+// resourceAttributes returns the attributes of the task resource, looking
+// up the task the first time.
+func (o *otlp) resourceAttributes(ctx context.Context) ([]*commonpb.KeyValue, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.resource == nil {
+		task, err := o.client.RetrieveTaskMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		o.resource = keyValues(taskResource(task, o.cfg.ResourceAttributes))
+	}
+	return o.resource, nil
+}
+
+// taskResource returns the resource attributes of task, following the
+// semantic conventions for cloud and aws.ecs.* attributes, with extra
+// attributes added.
+func taskResource(task *tmdsv4.TaskResponse, extra map[string]string) []label {
+	attrs := map[string]string{
+		"cloud.provider":          "aws",
+		"cloud.platform":          "aws_ecs",
+		"cloud.region":            ecscollector.TaskLabelValue(task, "region"),
+		"cloud.account.id":        ecscollector.TaskLabelValue(task, "account_id"),
+		"cloud.availability_zone": task.AvailabilityZone,
+		"aws.ecs.cluster.arn":     clusterARN(task),
+		"aws.ecs.task.arn":        task.TaskARN,
+		"aws.ecs.task.id":         ecscollector.TaskLabelValue(task, "task_id"),
+		"aws.ecs.task.family":     task.Family,
+		"aws.ecs.task.revision":   task.Revision,
+		"aws.ecs.launchtype":      strings.ToLower(task.LaunchType),
+	}
+	for name, value := range extra {
+		attrs[name] = value
+	}
+	resource := make([]label, 0, len(attrs))
+	for name, value := range attrs {
+		if value != "" {
+			resource = append(resource, label{name, value})
+		}
+	}
+	sort.Slice(resource, func(i, j int) bool { return resource[i].name < resource[j].name })
+	return resource
+}
+
+// clusterARN returns the ARN of the task's cluster, which the metadata
+// endpoint serves as either an ARN or a name.
+func clusterARN(task *tmdsv4.TaskResponse) string {
+	if strings.HasPrefix(task.Cluster, "arn:") {
+		return task.Cluster
+	}
+	region, account := ecscollector.TaskLabelValue(task, "region"), ecscollector.TaskLabelValue(task, "account_id")
+	if region == "" || account == "" {
+		return ""
+	}
+	return fmt.Sprintf("arn:aws:ecs:%s:%s:cluster/%s", region, account, task.Cluster)
+}
+
+// otlpAttributeNames maps metric labels to the attributes of the semantic
+// conventions. Other labels keep their name.
+var otlpAttributeNames = map[string]string{
+	"container_name": "container.name",
+}
+
+func otlpAttributes(labels []*dto.LabelPair) []*commonpb.KeyValue {
+	attrs := make([]label, 0, len(labels))
+	for _, lp := range labels {
+		name := lp.GetName()
+		if renamed, ok := otlpAttributeNames[name]; ok {
+			name = renamed
+		}
+		attrs = append(attrs, label{name, lp.GetValue()})
+	}
+	return keyValues(attrs)
+}
+
+// keyValues returns attrs as attributes with string values.
+func keyValues(attrs []label) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = append(kvs, &commonpb.KeyValue{
+			Key:   a.name,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: a.value}},
+		})
+	}
+	return kvs
+}
+
+// otlpUnit returns the UCUM unit of mf, from its name if it has none.
+func otlpUnit(mf *dto.MetricFamily) string {
+	if mf.GetUnit() != "" {
+		return mf.GetUnit()
+	}
+	name := strings.TrimSuffix(mf.GetName(), "_total")
+	switch {
+	case strings.HasSuffix(name, "_seconds"):
+		return "s"
+	case strings.HasSuffix(name, "_bytes"):
+		return "By"
+	}
+	return ""
+}
+
+// exportRequest returns mfs as an ExportMetricsServiceRequest with a single
+// resource. startNs is the start time of cumulative metrics without a created
+// timestamp, and nowNs the time of metrics without a timestamp.
+func exportRequest(mfs []*dto.MetricFamily, resource []*commonpb.KeyValue, startNs, nowNs uint64) *colmetricspb.ExportMetricsServiceRequest {
+	scope := &metricspb.ScopeMetrics{
+		Scope: &commonpb.InstrumentationScope{
+			Name:    "github.com/prometheus-community/ecs_exporter",
+			Version: version.Version,
+		},
+	}
+	for _, mf := range mfs {
+		scope.Metrics = append(scope.Metrics, otlpMetric(mf, startNs, nowNs))
+	}
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource:     &resourcepb.Resource{Attributes: resource},
+			ScopeMetrics: []*metricspb.ScopeMetrics{scope},
+		}},
+	}
+}
+
+func otlpMetric(mf *dto.MetricFamily, startNs, nowNs uint64) *metricspb.Metric {
+	var (
+		numbers    []*metricspb.NumberDataPoint
+		histograms []*metricspb.HistogramDataPoint
+		summaries  []*metricspb.SummaryDataPoint
+	)
+	for _, m := range mf.Metric {
+		timeNs := nowNs
+		if m.TimestampMs != nil {
+			timeNs = uint64(m.GetTimestampMs()) * uint64(time.Millisecond)
+		}
+		attrs := otlpAttributes(m.Label)
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			numbers = append(numbers, &metricspb.NumberDataPoint{
+				Attributes:        attrs,
+				StartTimeUnixNano: startTime(m.GetCounter().GetCreatedTimestamp().AsTime(), startNs),
+				TimeUnixNano:      timeNs,
+				Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: m.GetCounter().GetValue()},
+			})
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			value := m.GetGauge().GetValue()
+			if mf.GetType() == dto.MetricType_UNTYPED {
+				value = m.GetUntyped().GetValue()
+			}
+			numbers = append(numbers, &metricspb.NumberDataPoint{
+				Attributes:   attrs,
+				TimeUnixNano: timeNs,
+				Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+			})
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			h := m.GetHistogram()
+			// OTLP buckets aren't cumulative, and the +Inf bucket is
+			// implicit.
+			var bounds []float64
+			var counts []uint64
+			var prev uint64
+			for _, bucket := range h.Bucket {
+				if math.IsInf(bucket.GetUpperBound(), +1) {
+					continue
+				}
+				bounds = append(bounds, bucket.GetUpperBound())
+				counts = append(counts, bucket.GetCumulativeCount()-prev)
+				prev = bucket.GetCumulativeCount()
+			}
+			counts = append(counts, h.GetSampleCount()-prev)
+			histograms = append(histograms, &metricspb.HistogramDataPoint{
+				Attributes:        attrs,
+				StartTimeUnixNano: startTime(h.GetCreatedTimestamp().AsTime(), startNs),
+				TimeUnixNano:      timeNs,
+				Count:             h.GetSampleCount(),
+				Sum:               proto.Float64(h.GetSampleSum()),
+				BucketCounts:      counts,
+				ExplicitBounds:    bounds,
+			})
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			point := &metricspb.SummaryDataPoint{
+				Attributes:        attrs,
+				StartTimeUnixNano: startTime(s.GetCreatedTimestamp().AsTime(), startNs),
+				TimeUnixNano:      timeNs,
+				Count:             s.GetSampleCount(),
+				Sum:               s.GetSampleSum(),
+			}
+			for _, q := range s.Quantile {
+				point.QuantileValues = append(point.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+					Quantile: q.GetQuantile(),
+					Value:    q.GetValue(),
+				})
+			}
+			summaries = append(summaries, point)
+		}
+	}
+
+	metric := &metricspb.Metric{
+		Name:        mf.GetName(),
+		Description: mf.GetHelp(),
+		Unit:        otlpUnit(mf),
+	}
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+			DataPoints:             numbers,
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            true,
+		}}
+	case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+		metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: numbers}}
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		metric.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+			DataPoints:             histograms,
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		}}
+	case dto.MetricType_SUMMARY:
+		metric.Data = &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: summaries}}
+	}
+	return metric
+}
+
+// startTime returns created in nanoseconds, or defaultNs if it is unset.
+func startTime(created time.Time, defaultNs uint64) uint64 {
+	if created.Unix() <= 0 {
+		return defaultNs
+	}
+	return uint64(created.UnixNano())
+}
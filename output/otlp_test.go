@@ -0,0 +1,216 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// attributes returns the string values of kvs by key.
+func attributes(kvs []*commonpb.KeyValue) map[string]string {
+	attrs := make(map[string]string)
+	for _, kv := range kvs {
+		attrs[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+	return attrs
+}
+
+// checkExportRequest checks the ECS metrics of the fargate fixture in an
+// ExportMetricsServiceRequest.
+func checkExportRequest(t *testing.T, req *colmetricspb.ExportMetricsServiceRequest) {
+	t.Helper()
+	if len(req.GetResourceMetrics()) != 1 {
+		t.Fatalf("got %d resource metrics, want 1", len(req.GetResourceMetrics()))
+	}
+	resourceMetrics := req.GetResourceMetrics()[0]
+	resource := attributes(resourceMetrics.GetResource().GetAttributes())
+	for name, want := range map[string]string{
+		"cloud.platform":        "aws_ecs",
+		"cloud.region":          "us-east-1",
+		"aws.ecs.cluster.arn":   "arn:aws:ecs:us-east-1:829490980523:cluster/prom-ecs-exporter-sandbox",
+		"aws.ecs.task.arn":      "arn:aws:ecs:us-east-1:829490980523:task/prom-ecs-exporter-sandbox/bae32def0ab64f06818e8862e58f8d6d",
+		"aws.ecs.task.family":   "prom-ecs-exporter-sandbox-main-fargate",
+		"aws.ecs.launchtype":    "fargate",
+		"service.name":          "my-service",
+		"aws.ecs.task.revision": "9",
+	} {
+		if got := resource[name]; got != want {
+			t.Errorf("got resource attribute %s=%q, want %q", name, got, want)
+		}
+	}
+
+	var found bool
+	for _, scope := range resourceMetrics.GetScopeMetrics() {
+		for _, metric := range scope.GetMetrics() {
+			if metric.GetName() != "ecs_container_cpu_usage_seconds_total" {
+				continue
+			}
+			found = true
+			if unit := metric.GetUnit(); unit != "s" {
+				t.Errorf("got unit %q, want s", unit)
+			}
+			sum := metric.GetSum()
+			if sum.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE || !sum.GetIsMonotonic() {
+				t.Errorf("got sum %v, want a cumulative monotonic sum", sum)
+			}
+			values := make(map[string]float64)
+			for _, point := range sum.GetDataPoints() {
+				attrs := attributes(point.GetAttributes())
+				values[attrs["container.name"]] = point.GetAsDouble()
+				if point.GetStartTimeUnixNano() == 0 {
+					t.Errorf("got data point of %v without a start time", attrs)
+				}
+				if point.GetTimeUnixNano() == 0 {
+					t.Errorf("got data point of %v without a time", attrs)
+				}
+			}
+			if got, want := values["prometheus"], 0.9324394920000001; got != want {
+				t.Errorf("got CPU usage %v for container.name=prometheus, want %v", got, want)
+			}
+		}
+	}
+	if !found {
+		t.Error("missing ecs_container_cpu_usage_seconds_total")
+	}
+}
+
+func testOTLPConfig(endpoint, protocol string) OTLPConfig {
+	cfg := DefaultOTLPConfig
+	cfg.Endpoint = endpoint
+	cfg.Protocol = protocol
+	cfg.ResourceAttributes = map[string]string{"service.name": "my-service"}
+	return cfg
+}
+
+func TestOTLPHTTP(t *testing.T) {
+	server := fixtureServer(t)
+	mfs, err := fixtureGatherer(t, server).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu     sync.Mutex
+		bodies [][]byte
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("got request to %s with content type %q, want OTLP/HTTP protobuf", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, body)
+	}))
+	defer receiver.Close()
+
+	o, err := newOTLP(testOTLPConfig(receiver.URL, otlpHTTP), server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Push(context.Background(), mfs); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(bodies))
+	}
+	var req colmetricspb.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(bodies[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	checkExportRequest(t, &req)
+}
+
+// metricsService is an OTLP/gRPC receiver, which records the requests it
+// receives and fails with err.
+type metricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+
+	mu       sync.Mutex
+	requests []*colmetricspb.ExportMetricsServiceRequest
+	headers  []metadata.MD
+	err      error
+}
+
+func (s *metricsService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	s.headers = append(s.headers, md)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestOTLPGRPC(t *testing.T) {
+	server := fixtureServer(t)
+	mfs, err := fixtureGatherer(t, server).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &metricsService{}
+	receiver := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(receiver, service)
+	go receiver.Serve(l)
+	defer receiver.Stop()
+
+	cfg := testOTLPConfig("http://"+l.Addr().String(), otlpGRPC)
+	cfg.Headers = map[string]string{"X-Scope-OrgID": "tenant"}
+	o, err := newOTLP(cfg, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close(context.Background())
+	if err := o.Push(context.Background(), mfs); err != nil {
+		t.Fatal(err)
+	}
+	service.mu.Lock()
+	if len(service.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(service.requests))
+	}
+	checkExportRequest(t, service.requests[0])
+	if got := service.headers[0].Get("x-scope-orgid"); !slices.Equal(got, []string{"tenant"}) {
+		t.Errorf("got X-Scope-OrgID metadata %q, want tenant", got)
+	}
+	service.err = status.Error(codes.InvalidArgument, "bad metrics")
+	service.mu.Unlock()
+
+	err = o.Push(context.Background(), mfs)
+	if s, ok := status.FromError(err); !ok || s.Code() != codes.InvalidArgument || s.Message() != "bad metrics" {
+		t.Errorf("got error %v, want gRPC status InvalidArgument: bad metrics", err)
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package output pushes the metrics served by ecs_exporter to systems which
+// can't scrape it, e.g. because the task is too short-lived or unreachable.
+//
+// Each output is driven by a Runner, which gathers metrics on an interval,
+// when the task is stopping, and one last time on shutdown.
+package output
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// Output sends gathered metrics somewhere.
+type Output interface {
+	// Push sends mfs, which it may modify.
+	Push(ctx context.Context, mfs []*dto.MetricFamily) error
+}
+
+// Closer is implemented by outputs which clean up on shutdown, after the
+// final push.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Config configures the outputs, from the outputs section of the
+// configuration file. Outputs left out are disabled.
+type Config struct {
+	Pushgateway *PushgatewayConfig `yaml:"pushgateway"`
+	RemoteWrite *RemoteWriteConfig `yaml:"remote_write"`
+	OTLP        *OTLPConfig        `yaml:"otlp"`
+	EMF         *EMFConfig         `yaml:"emf"`
+	DogStatsD   *DogStatsDConfig   `yaml:"dogstatsd"`
+	Influx      *InfluxConfig      `yaml:"influx"`
+	Graphite    *GraphiteConfig    `yaml:"graphite"`
+}
+
+// PushConfig holds the settings common to all outputs.
+type PushConfig struct {
+	// Interval is how often metrics are pushed.
+	Interval model.Duration `yaml:"interval"`
+	// Timeout limits each push.
+	Timeout model.Duration `yaml:"timeout"`
+}
+
+// DefaultPushConfig is the default PushConfig, which the settings left out
+// of an output's configuration default to.
+var DefaultPushConfig = PushConfig{
+	Interval: model.Duration(time.Minute),
+	Timeout:  model.Duration(10 * time.Second),
+}
+
+func (c PushConfig) validate(output string) error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("%s output requires a positive interval", output)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("%s output requires a positive timeout", output)
+	}
+	return nil
+}
+
+// New returns Runners for the outputs configured in cfg, pushing the metrics
+// gathered from g. The client is used to find out about the task.
+func New(cfg Config, g prometheus.Gatherer, client *ecsmetadata.Client, logger *slog.Logger, metrics *Metrics) ([]*Runner, error) {
+	var runners []*Runner
+	for _, o := range configuredOutputs(cfg, g, client, logger, metrics) {
+		r, err := o.build()
+		if err != nil {
+			return nil, err
+		}
+		runners = append(runners, r)
+	}
+	return runners, nil
+}
+
+// configuredOutput is an output enabled in a Config.
+type configuredOutput struct {
+	name string
+	// cfg is the output's own configuration, which decides whether it
+	// must be restarted when the configuration file is reloaded.
+	cfg   any
+	build func() (*Runner, error)
+}
+
+// configuredOutputs returns the outputs enabled in cfg, with arguments like
+// those of New.
+func configuredOutputs(cfg Config, g prometheus.Gatherer, client *ecsmetadata.Client, logger *slog.Logger, metrics *Metrics) []configuredOutput {
+	var outputs []configuredOutput
+	add := func(name string, cfg any, push PushConfig, newOutput func() (Output, error)) {
+		outputs = append(outputs, configuredOutput{name: name, cfg: cfg, build: func() (*Runner, error) {
+			o, err := newOutput()
+			if err != nil {
+				return nil, fmt.Errorf("%s output: %w", name, err)
+			}
+			return NewRunner(name, o, g, push, logger, metrics), nil
+		}})
+	}
+	if c := cfg.Pushgateway; c != nil {
+		add("pushgateway", *c, c.PushConfig, func() (Output, error) { return newPushgateway(*c, client), nil })
+	}
+	if c := cfg.RemoteWrite; c != nil {
+		add("remote_write", *c, c.PushConfig, func() (Output, error) { return newRemoteWrite(*c, metrics) })
+	}
+	if c := cfg.OTLP; c != nil {
+		add("otlp", *c, c.PushConfig, func() (Output, error) { return newOTLP(*c, client) })
+	}
+	if c := cfg.EMF; c != nil {
+		add("emf", *c, c.PushConfig, func() (Output, error) { return newEMF(*c, client, os.Stdout), nil })
+	}
+	if c := cfg.DogStatsD; c != nil {
+		add("dogstatsd", *c, c.PushConfig, func() (Output, error) { return newDogStatsD(*c), nil })
+	}
+	if c := cfg.Influx; c != nil {
+		add("influx", *c, c.PushConfig, func() (Output, error) { return newInflux(*c), nil })
+	}
+	if c := cfg.Graphite; c != nil {
+		add("graphite", *c, c.PushConfig, func() (Output, error) { return newGraphite(*c), nil })
+	}
+	return outputs
+}
+
+// Runner pushes metrics to an Output.
+type Runner struct {
+	name     string
+	output   Output
+	gatherer prometheus.Gatherer
+	cfg      PushConfig
+	logger   *slog.Logger
+	metrics  *Metrics
+	flush    chan struct{}
+}
+
+// NewRunner returns a Runner pushing the metrics gathered from g to output,
+// reporting on the push in metrics under name.
+func NewRunner(name string, output Output, g prometheus.Gatherer, cfg PushConfig, logger *slog.Logger, metrics *Metrics) *Runner {
+	return &Runner{
+		name:     name,
+		output:   output,
+		gatherer: g,
+		cfg:      cfg,
+		logger:   logger.With("output", name),
+		metrics:  metrics,
+		flush:    make(chan struct{}, 1),
+	}
+}
+
+// Flush makes the Runner push without waiting for the next interval.
+func (r *Runner) Flush() {
+	select {
+	case r.flush <- struct{}{}:
+	default:
+	}
+}
+
+// Run pushes metrics right away and then on every interval until ctx is
+// done, when it pushes one last time and closes the output.
+func (r *Runner) Run(ctx context.Context) {
+	r.push(ctx)
+	ticker := time.NewTicker(time.Duration(r.cfg.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.push(ctx)
+		case <-r.flush:
+			r.push(ctx)
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), time.Duration(r.cfg.Timeout))
+			defer cancel()
+			r.push(final)
+			if closer, ok := r.output.(Closer); ok {
+				if err := closer.Close(final); err != nil {
+					r.logger.Error("Error closing output", "err", err)
+				}
+			}
+			return
+		}
+	}
+}
+
+func (r *Runner) push(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(r.cfg.Timeout))
+	defer cancel()
+	mfs, err := r.gatherer.Gather()
+	if err != nil {
+		if len(mfs) == 0 {
+			// Pushing nothing would e.g. wipe the Pushgateway group.
+			r.logger.Error("Error gathering metrics to push, skipping the push", "err", err)
+			return
+		}
+		// Like the /metrics handler, push whatever could be gathered.
+		r.logger.Warn("Error gathering metrics to push", "err", err)
+	}
+	r.metrics.pushes.WithLabelValues(r.name).Inc()
+	if err := r.output.Push(ctx, mfs); err != nil {
+		r.metrics.failures.WithLabelValues(r.name).Inc()
+		r.logger.Error("Error pushing metrics", "err", err)
+		return
+	}
+	r.metrics.lastSuccess.WithLabelValues(r.name).SetToCurrentTime()
+}
+
+// Run runs runners until ctx is done and they have all pushed one last time.
+// They are also flushed once the task is stopping, as its DesiredStatus
+// becomes STOPPED.
+func Run(ctx context.Context, runners []*Runner, client *ecsmetadata.Client, logger *slog.Logger) {
+	if len(runners) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, r := range runners {
+		wg.Go(func() { r.Run(ctx) })
+	}
+	go watchTaskStop(ctx, client, taskPollInterval, logger, func() {
+		for _, r := range runners {
+			r.Flush()
+		}
+	})
+	wg.Wait()
+}
+
+// Manager runs the outputs of the current configuration, and restarts those
+// whose configuration changes when the configuration file is reloaded.
+type Manager struct {
+	gatherer prometheus.Gatherer
+	client   *ecsmetadata.Client
+	logger   *slog.Logger
+	metrics  *Metrics
+
+	mu        sync.Mutex
+	running   map[string]*managedRunner
+	stopWatch context.CancelFunc
+}
+
+// managedRunner is a Runner started by a Manager.
+type managedRunner struct {
+	cfg    any
+	runner *Runner
+	stop   context.CancelFunc
+	done   chan struct{}
+}
+
+// NewManager returns a Manager pushing the metrics gathered from g, with
+// arguments like those of New.
+func NewManager(g prometheus.Gatherer, client *ecsmetadata.Client, logger *slog.Logger, metrics *Metrics) *Manager {
+	return &Manager{gatherer: g, client: client, logger: logger, metrics: metrics, running: make(map[string]*managedRunner)}
+}
+
+// Apply starts the outputs configured in cfg. Outputs whose configuration
+// changed are restarted, and those left out are stopped, once they have
+// pushed one last time; the others keep running along with their state,
+// e.g. queued samples or the previous values of counters. On error, the
+// previous outputs are kept.
+func (m *Manager) Apply(cfg Config) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	outputs := configuredOutputs(cfg, m.gatherer, m.client, m.logger, m.metrics)
+	started := make(map[string]*managedRunner)
+	for _, o := range outputs {
+		if r, ok := m.running[o.name]; ok && reflect.DeepEqual(r.cfg, o.cfg) {
+			continue
+		}
+		runner, err := o.build()
+		if err != nil {
+			return err
+		}
+		started[o.name] = &managedRunner{cfg: o.cfg, runner: runner}
+	}
+
+	var stopped []*managedRunner
+	for name, r := range m.running {
+		_, restarted := started[name]
+		enabled := slices.ContainsFunc(outputs, func(o configuredOutput) bool { return o.name == name })
+		if restarted || !enabled {
+			stopped = append(stopped, r)
+			delete(m.running, name)
+		}
+	}
+	stopRunners(stopped)
+
+	for name, r := range started {
+		ctx, stop := context.WithCancel(context.Background())
+		r.stop, r.done = stop, make(chan struct{})
+		go func() {
+			r.runner.Run(ctx)
+			close(r.done)
+		}()
+		m.running[name] = r
+	}
+	if len(m.running) > 0 && m.stopWatch == nil {
+		ctx, stop := context.WithCancel(context.Background())
+		m.stopWatch = stop
+		go watchTaskStop(ctx, m.client, taskPollInterval, m.logger, m.flush)
+	}
+	return nil
+}
+
+// flush flushes the running outputs.
+func (m *Manager) flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.running {
+		r.runner.Flush()
+	}
+}
+
+// Stop stops the outputs, once they have pushed one last time.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopWatch != nil {
+		m.stopWatch()
+		m.stopWatch = nil
+	}
+	stopRunners(slices.Collect(maps.Values(m.running)))
+	clear(m.running)
+}
+
+// stopRunners stops runners concurrently, and waits for their last push.
+func stopRunners(runners []*managedRunner) {
+	for _, r := range runners {
+		r.stop()
+	}
+	for _, r := range runners {
+		<-r.done
+	}
+}
+
+// Metrics reports on the pushes of Runners.
+type Metrics struct {
+	pushes      *prometheus.CounterVec
+	failures    *prometheus.CounterVec
+	lastSuccess *prometheus.GaugeVec
+	dropped     *prometheus.CounterVec
+}
+
+// NewMetrics returns Metrics to be registered with the exporter's own
+// metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		pushes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ecs_exporter_output_pushes_total",
+			Help: "Number of attempts to push metrics to each output.",
+		}, []string{"output"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ecs_exporter_output_push_failures_total",
+			Help: "Number of failed attempts to push metrics to each output.",
+		}, []string{"output"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "ecs_exporter_output_last_push_success_timestamp_seconds",
+			Help: "Timestamp of the last successful push of metrics to each output.",
+		}, []string{"output"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ecs_exporter_output_samples_dropped_total",
+			Help: "Number of samples dropped by each output, because its queue was full or they were rejected.",
+		}, []string{"output"}),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.pushes.Describe(ch)
+	m.failures.Describe(ch)
+	m.lastSuccess.Describe(ch)
+	m.dropped.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.pushes.Collect(ch)
+	m.failures.Collect(ch)
+	m.lastSuccess.Collect(ch)
+	m.dropped.Collect(ch)
+}
@@ -0,0 +1,155 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestManager(t *testing.T) {
+	server := fixtureServer(t)
+	newServer := func() (*httptest.Server, *atomic.Int32) {
+		var pushes atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pushes.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(s.Close)
+		return s, &pushes
+	}
+	// The outputs only push on startup and shutdown.
+	influxConfig := func(url string) Config {
+		cfg := DefaultInfluxConfig
+		cfg.URL = url
+		cfg.Interval = model.Duration(time.Hour)
+		return Config{Influx: &cfg}
+	}
+	withPushgateway := func(cfg Config, url string) Config {
+		pg := DefaultPushgatewayConfig
+		pg.URL = url
+		pg.Interval = model.Duration(time.Hour)
+		cfg.Pushgateway = &pg
+		return cfg
+	}
+	waitFor := func(pushes *atomic.Int32, want int32) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); pushes.Load() < want; {
+			if time.Now().After(deadline) {
+				t.Fatalf("got %d pushes, want %d", pushes.Load(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	first, firstPushes := newServer()
+	second, secondPushes := newServer()
+	pushgateway, pushgatewayPushes := newServer()
+	m := NewManager(fixtureGatherer(t, server), server.Client(), slog.Default(), NewMetrics())
+	if err := m.Apply(influxConfig(first.URL)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(firstPushes, 1)
+
+	// An unchanged configuration leaves the outputs running.
+	if err := m.Apply(influxConfig(first.URL)); err != nil {
+		t.Fatal(err)
+	}
+	if got := firstPushes.Load(); got != 1 {
+		t.Errorf("got %d pushes after applying the same configuration, want 1", got)
+	}
+
+	// Adding an output leaves the others running.
+	if err := m.Apply(withPushgateway(influxConfig(first.URL), pushgateway.URL)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(pushgatewayPushes, 1)
+	if got := firstPushes.Load(); got != 1 {
+		t.Errorf("got %d pushes after adding an output, want 1", got)
+	}
+
+	// A changed output is stopped after a final push, and restarted, while
+	// the others keep running.
+	if err := m.Apply(withPushgateway(influxConfig(second.URL), pushgateway.URL)); err != nil {
+		t.Fatal(err)
+	}
+	if got := firstPushes.Load(); got != 2 {
+		t.Errorf("got %d pushes to the replaced output, want 2", got)
+	}
+	waitFor(secondPushes, 1)
+	if got := pushgatewayPushes.Load(); got != 1 {
+		t.Errorf("got %d pushes to the unchanged output, want 1", got)
+	}
+
+	// An output left out is stopped after a final push.
+	if err := m.Apply(influxConfig(second.URL)); err != nil {
+		t.Fatal(err)
+	}
+	if got := pushgatewayPushes.Load(); got != 2 {
+		t.Errorf("got %d pushes to the removed output, want 2", got)
+	}
+
+	m.Stop()
+	if got := secondPushes.Load(); got != 2 {
+		t.Errorf("got %d pushes after stopping, want 2", got)
+	}
+	if got := firstPushes.Load(); got != 2 {
+		t.Errorf("got %d pushes to the replaced output after stopping, want 2", got)
+	}
+	if got := pushgatewayPushes.Load(); got != 2 {
+		t.Errorf("got %d pushes to the removed output after stopping, want 2", got)
+	}
+}
+
+// countingOutput counts the pushes it receives.
+type countingOutput struct {
+	pushes atomic.Int32
+}
+
+func (o *countingOutput) Push(ctx context.Context, mfs []*dto.MetricFamily) error {
+	o.pushes.Add(1)
+	return nil
+}
+
+func TestRunnerSkipsFailedGather(t *testing.T) {
+	var mfs []*dto.MetricFamily
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return mfs, errors.New("gather failed")
+	})
+	output := &countingOutput{}
+	r := NewRunner("test", output, g, DefaultPushConfig, slog.Default(), NewMetrics())
+
+	r.push(context.Background())
+	if got := output.pushes.Load(); got != 0 {
+		t.Errorf("got %d pushes without any metrics gathered, want 0", got)
+	}
+
+	// Metrics gathered despite the error are pushed.
+	mfs = []*dto.MetricFamily{{Name: proto.String("up")}}
+	r.push(context.Background())
+	if got := output.pushes.Load(); got != 1 {
+		t.Errorf("got %d pushes with some metrics gathered, want 1", got)
+	}
+}
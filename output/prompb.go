@@ -0,0 +1,201 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// timeSeries is a single sample of a series, as sent with remote write.
+type timeSeries struct {
+	// labels are sorted by name, and include the metric name.
+	labels      []label
+	value       float64
+	timestampMs int64
+}
+
+type label struct {
+	name, value string
+}
+
+// seriesOf flattens mfs into samples, the way Prometheus would ingest them
+// when scraping: summaries and histograms are split into their quantile or
+// bucket, sum and count series. Metrics without a timestamp get nowMs, and
+// extra labels are added to every series unless already set.
+func seriesOf(mfs []*dto.MetricFamily, extra map[string]string, nowMs int64) []timeSeries {
+	var series []timeSeries
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			ts := nowMs
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			add := func(name string, value float64, extraName, extraValue string) {
+				labels := make([]label, 0, len(m.Label)+len(extra)+2)
+				labels = append(labels, label{model.MetricNameLabel, name})
+				set := make(map[string]bool, len(m.Label))
+				for _, lp := range m.Label {
+					labels = append(labels, label{lp.GetName(), lp.GetValue()})
+					set[lp.GetName()] = true
+				}
+				if extraName != "" {
+					labels = append(labels, label{extraName, extraValue})
+				}
+				for n, v := range extra {
+					if !set[n] {
+						labels = append(labels, label{n, v})
+					}
+				}
+				sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+				series = append(series, timeSeries{labels: labels, value: value, timestampMs: ts})
+			}
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue(), "", "")
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue(), "", "")
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue(), "", "")
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.Quantile {
+					add(name, q.GetValue(), model.QuantileLabel, formatFloat(q.GetQuantile()))
+				}
+				add(name+"_sum", s.GetSampleSum(), "", "")
+				add(name+"_count", float64(s.GetSampleCount()), "", "")
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				infSeen := false
+				for _, b := range h.Bucket {
+					if math.IsInf(b.GetUpperBound(), +1) {
+						infSeen = true
+					}
+					add(name+"_bucket", float64(b.GetCumulativeCount()), model.BucketLabel, formatFloat(b.GetUpperBound()))
+				}
+				if !infSeen {
+					add(name+"_bucket", float64(h.GetSampleCount()), model.BucketLabel, "+Inf")
+				}
+				add(name+"_sum", h.GetSampleSum(), "", "")
+				add(name+"_count", float64(h.GetSampleCount()), "", "")
+			}
+		}
+	}
+	return series
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Field numbers of the remote write 1.0 protobuf messages, from
+// prometheus/prompb.
+const (
+	writeRequestTimeseries = 1
+	timeSeriesLabels       = 1
+	timeSeriesSamples      = 2
+	labelName              = 1
+	labelValue             = 2
+	sampleValue            = 1
+	sampleTimestamp        = 2
+)
+
+// marshalWriteRequest encodes series as a prometheus.WriteRequest message.
+// The samples of the same label set are sent in one TimeSeries, in timestamp
+// order; of samples with the same timestamp, the last one queued is kept.
+func marshalWriteRequest(series []timeSeries) []byte {
+	var b []byte
+	for _, samples := range groupSeries(series) {
+		b = protowire.AppendTag(b, writeRequestTimeseries, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalTimeSeries(samples))
+	}
+	return b
+}
+
+// groupSeries groups the samples of series by label set, in the order each
+// label set first appears.
+func groupSeries(series []timeSeries) [][]timeSeries {
+	var groups [][]timeSeries
+	index := make(map[string]int)
+	for _, s := range series {
+		key := labelsKey(s.labels)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], s)
+	}
+	for i, samples := range groups {
+		sort.SliceStable(samples, func(i, j int) bool { return samples[i].timestampMs < samples[j].timestampMs })
+		deduped := samples[:0]
+		for _, s := range samples {
+			if n := len(deduped); n > 0 && deduped[n-1].timestampMs == s.timestampMs {
+				deduped[n-1] = s
+				continue
+			}
+			deduped = append(deduped, s)
+		}
+		groups[i] = deduped
+	}
+	return groups
+}
+
+// labelsKey returns a string identifying sorted labels.
+func labelsKey(labels []label) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.name)
+		b.WriteByte(0xff)
+		b.WriteString(l.value)
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
+
+// marshalTimeSeries encodes samples, which share their labels, as a
+// prometheus.TimeSeries message.
+func marshalTimeSeries(samples []timeSeries) []byte {
+	var b []byte
+	for _, l := range samples[0].labels {
+		var lb []byte
+		lb = protowire.AppendTag(lb, labelName, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, labelValue, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+		b = protowire.AppendTag(b, timeSeriesLabels, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+	for _, s := range samples {
+		var sb []byte
+		sb = protowire.AppendTag(sb, sampleValue, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.value))
+		sb = protowire.AppendTag(sb, sampleTimestamp, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.timestampMs))
+		b = protowire.AppendTag(b, timeSeriesSamples, protowire.BytesType)
+		b = protowire.AppendBytes(b, sb)
+	}
+	return b
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// groupingLabel is the label grouping the metrics of each task on the
+// Pushgateway.
+const groupingLabel = "task_arn"
+
+// PushgatewayConfig configures pushing to a Pushgateway.
+type PushgatewayConfig struct {
+	PushConfig `yaml:",inline"`
+	// URL is the Pushgateway's base URL, e.g. http://pushgateway:9091.
+	URL string `yaml:"url"`
+	// Job is the job label of pushed metrics.
+	Job string `yaml:"job"`
+	// DeleteOnShutdown deletes the task's metrics from the Pushgateway after
+	// the final push, e.g. for services, where they would otherwise be
+	// exposed forever once the task is replaced.
+	DeleteOnShutdown bool `yaml:"delete_on_shutdown"`
+}
+
+// DefaultPushgatewayConfig is the default PushgatewayConfig, which fields
+// left out of it in YAML default to.
+var DefaultPushgatewayConfig = PushgatewayConfig{
+	PushConfig: DefaultPushConfig,
+	Job:        "ecs_exporter",
+}
+
+func (c *PushgatewayConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultPushgatewayConfig
+	type plain PushgatewayConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.URL == "" {
+		return errors.New("pushgateway output requires url")
+	}
+	if c.Job == "" {
+		return errors.New("pushgateway output requires job")
+	}
+	return c.validate("pushgateway")
+}
+
+// pushgateway pushes metrics to a Pushgateway, grouped by task ARN. Each push
+// replaces the metrics of the previous one.
+type pushgateway struct {
+	cfg        PushgatewayConfig
+	client     *ecsmetadata.Client
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	taskARN string
+}
+
+func newPushgateway(cfg PushgatewayConfig, client *ecsmetadata.Client) *pushgateway {
+	return &pushgateway{cfg: cfg, client: client, httpClient: &http.Client{}}
+}
+
+func (p *pushgateway) Push(ctx context.Context, mfs []*dto.MetricFamily) error {
+	pusher, err := p.pusher(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			// The Pushgateway rejects timestamps, and the job and grouping
+			// labels are set by the push itself. A task_arn label added
+			// with --labels.task has the same value anyway.
+			m.TimestampMs = nil
+			m.Label = slices.DeleteFunc(m.Label, func(l *dto.LabelPair) bool {
+				return l.GetName() == "job" || l.GetName() == groupingLabel
+			})
+		}
+	}
+	return pusher.Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return mfs, nil
+	})).PushContext(ctx)
+}
+
+// Close deletes the task's metrics if configured to.
+func (p *pushgateway) Close(ctx context.Context) error {
+	if !p.cfg.DeleteOnShutdown {
+		return nil
+	}
+	pusher, err := p.pusher(ctx)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		pusher.Client(&http.Client{Timeout: time.Until(deadline)})
+	}
+	return pusher.Delete()
+}
+
+// pusher returns a Pusher for the task's group, looking up the task ARN the
+// first time.
+func (p *pushgateway) pusher(ctx context.Context) (*push.Pusher, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.taskARN == "" {
+		task, err := p.client.RetrieveTaskMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.taskARN = task.TaskARN
+	}
+	return push.New(p.cfg.URL, p.cfg.Job).Grouping(groupingLabel, p.taskARN).Client(p.httpClient), nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
+)
+
+func fixtureServer(t *testing.T) *ecsmetadatatest.Server {
+	t.Helper()
+	fixture, err := ecsmetadatatest.LoadFixture(
+		"../ecscollector/testdata/fixtures/fargate_task_metadata.json",
+		"../ecscollector/testdata/fixtures/fargate_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ecsmetadatatest.NewServer(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fixtureGatherer gathers the ECS metrics of the task served by server, with
+// the task ARN as a label.
+func fixtureGatherer(t *testing.T, server *ecsmetadatatest.Server) prometheus.Gatherer {
+	t.Helper()
+	collector, err := ecscollector.NewCollector(server.Client(), slog.Default(), ecscollector.Opts{
+		Collectors: []string{"container_cpu"},
+		TaskLabels: []string{"task_arn"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	return registry
+}
+
+type pushgatewayRequest struct {
+	method, path string
+	families     []*dto.MetricFamily
+}
+
+func TestPushgateway(t *testing.T) {
+	server := fixtureServer(t)
+	var (
+		mu       sync.Mutex
+		requests []pushgatewayRequest
+	)
+	pushgatewayServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := pushgatewayRequest{method: r.Method, path: r.URL.Path}
+		dec := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			mf := &dto.MetricFamily{}
+			if err := dec.Decode(mf); err != nil {
+				break
+			}
+			req.families = append(req.families, mf)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer pushgatewayServer.Close()
+
+	cfg := DefaultPushgatewayConfig
+	cfg.URL = pushgatewayServer.URL
+	cfg.Interval = model.Duration(time.Hour)
+	cfg.DeleteOnShutdown = true
+	metrics := NewMetrics()
+	runners, err := New(Config{Pushgateway: &cfg}, fixtureGatherer(t, server), server.Client(), slog.Default(), metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, runners, server.Client(), slog.Default())
+		close(done)
+	}()
+	for testutil.ToFloat64(metrics.lastSuccess.WithLabelValues("pushgateway")) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if got := testutil.ToFloat64(metrics.failures.WithLabelValues("pushgateway")); got != 0 {
+		t.Errorf("got %v failed pushes, want 0", got)
+	}
+
+	var taskARN string
+	server.Update(func(f *ecsmetadatatest.Fixture) { taskARN = f.TaskMetadata.TaskARN })
+	path := "/metrics/job/ecs_exporter/task_arn@base64/" + base64.RawURLEncoding.EncodeToString([]byte(taskARN))
+	mu.Lock()
+	defer mu.Unlock()
+	// An initial push, a final push on shutdown, and the deletion.
+	want := []string{http.MethodPut, http.MethodPut, http.MethodDelete}
+	if len(requests) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(requests), len(want))
+	}
+	for i, req := range requests {
+		if req.method != want[i] || req.path != path {
+			t.Errorf("got request %s %s, want %s %s", req.method, req.path, want[i], path)
+		}
+	}
+	families := requests[0].families
+	if len(families) != 1 || families[0].GetName() != "ecs_container_cpu_usage_seconds_total" {
+		t.Fatalf("got pushed families %v, want ecs_container_cpu_usage_seconds_total", families)
+	}
+	for _, m := range families[0].Metric {
+		for _, l := range m.Label {
+			if l.GetName() == groupingLabel {
+				t.Errorf("got pushed metric with grouping label %s", l)
+			}
+		}
+	}
+}
+
+func TestWatchTaskStop(t *testing.T) {
+	server := fixtureServer(t)
+	stopping := make(chan struct{})
+	go watchTaskStop(context.Background(), server.Client(), time.Millisecond, slog.Default(), func() { close(stopping) })
+
+	select {
+	case <-stopping:
+		t.Fatal("got stopping task while it is running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	server.Update(func(f *ecsmetadatatest.Fixture) { f.TaskMetadata.DesiredStatus = "STOPPED" })
+	select {
+	case <-stopping:
+	case <-time.After(time.Second):
+		t.Fatal("got no stopping task after DesiredStatus became STOPPED")
+	}
+}
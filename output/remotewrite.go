@@ -0,0 +1,197 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
+)
+
+// RemoteWriteConfig configures pushing with the Prometheus remote write
+// protocol, e.g. to Amazon Managed Service for Prometheus.
+type RemoteWriteConfig struct {
+	PushConfig `yaml:",inline"`
+	// URL is the remote write endpoint.
+	URL string `yaml:"url"`
+	// ExternalLabels are added to every series, unless already set.
+	ExternalLabels map[string]string `yaml:"external_labels"`
+	Queue          QueueConfig       `yaml:"queue"`
+	// SigV4 signs requests for Amazon Managed Service for Prometheus, with
+	// the task's IAM role.
+	SigV4 *SigV4Config `yaml:"sigv4"`
+}
+
+// QueueConfig configures the in-memory queue of samples waiting to be sent.
+// Samples are lost if ecs_exporter exits before sending them.
+type QueueConfig struct {
+	// Capacity is the maximum number of queued samples. Once full, the
+	// oldest samples are dropped.
+	Capacity int `yaml:"capacity"`
+	// MaxSamplesPerSend is the maximum number of samples per request.
+	MaxSamplesPerSend int `yaml:"max_samples_per_send"`
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// retries of a failed request.
+	MinBackoff model.Duration `yaml:"min_backoff"`
+	MaxBackoff model.Duration `yaml:"max_backoff"`
+}
+
+// DefaultRemoteWriteConfig is the default RemoteWriteConfig, which fields
+// left out of it in YAML default to.
+var DefaultRemoteWriteConfig = RemoteWriteConfig{
+	PushConfig: DefaultPushConfig,
+	Queue: QueueConfig{
+		Capacity:          10000,
+		MaxSamplesPerSend: 2000,
+		MinBackoff:        model.Duration(30 * time.Millisecond),
+		MaxBackoff:        model.Duration(5 * time.Second),
+	},
+}
+
+func (c *RemoteWriteConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultRemoteWriteConfig
+	type plain RemoteWriteConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.URL == "" {
+		return errors.New("remote_write output requires url")
+	}
+	if c.Queue.Capacity <= 0 || c.Queue.MaxSamplesPerSend <= 0 {
+		return errors.New("remote_write queue requires a positive capacity and max_samples_per_send")
+	}
+	if c.Queue.MinBackoff <= 0 || c.Queue.MaxBackoff < c.Queue.MinBackoff {
+		return errors.New("remote_write queue requires a positive min_backoff, no greater than max_backoff")
+	}
+	return c.validate("remote_write")
+}
+
+// remoteWrite sends metrics with the remote write 1.0 protocol. Samples are
+// queued, and those which couldn't be sent within a push are retried on the
+// next one.
+type remoteWrite struct {
+	cfg     RemoteWriteConfig
+	client  *http.Client
+	dropped prometheus.Counter
+	queue   []timeSeries
+}
+
+func newRemoteWrite(cfg RemoteWriteConfig, metrics *Metrics) (*remoteWrite, error) {
+	transport := http.DefaultTransport
+	if cfg.SigV4 != nil {
+		var err error
+		if transport, err = newSigV4RoundTripper(*cfg.SigV4, transport); err != nil {
+			return nil, err
+		}
+	}
+	return &remoteWrite{
+		cfg:     cfg,
+		client:  &http.Client{Transport: transport},
+		dropped: metrics.dropped.WithLabelValues("remote_write"),
+	}, nil
+}
+
+func (rw *remoteWrite) Push(ctx context.Context, mfs []*dto.MetricFamily) error {
+	rw.enqueue(seriesOf(mfs, rw.cfg.ExternalLabels, time.Now().UnixMilli()))
+	var errs []error
+	for len(rw.queue) > 0 {
+		batch := rw.queue[:min(len(rw.queue), rw.cfg.Queue.MaxSamplesPerSend)]
+		err := rw.sendWithRetry(ctx, batch)
+		var unrecoverable unrecoverableError
+		switch {
+		case errors.As(err, &unrecoverable):
+			// Retrying wouldn't help.
+			rw.dropped.Add(float64(len(batch)))
+			errs = append(errs, err)
+		case err != nil:
+			// Keep the batch queued for the next push.
+			return errors.Join(append(errs, err)...)
+		}
+		rw.queue = rw.queue[len(batch):]
+	}
+	return errors.Join(errs...)
+}
+
+// enqueue adds series to the queue, dropping the oldest samples if it
+// overflows.
+func (rw *remoteWrite) enqueue(series []timeSeries) {
+	rw.queue = append(rw.queue, series...)
+	if overflow := len(rw.queue) - rw.cfg.Queue.Capacity; overflow > 0 {
+		rw.dropped.Add(float64(overflow))
+		rw.queue = append([]timeSeries(nil), rw.queue[overflow:]...)
+	}
+}
+
+// sendWithRetry sends batch, retrying with backoff until it is sent, the
+// error is unrecoverable, or ctx is done.
+func (rw *remoteWrite) sendWithRetry(ctx context.Context, batch []timeSeries) error {
+	body := snappy.Encode(nil, marshalWriteRequest(batch))
+	backoff := time.Duration(rw.cfg.Queue.MinBackoff)
+	for {
+		err := rw.send(ctx, body)
+		var unrecoverable unrecoverableError
+		if err == nil || errors.As(err, &unrecoverable) {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, time.Duration(rw.cfg.Queue.MaxBackoff))
+	}
+}
+
+func (rw *remoteWrite) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rw.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return unrecoverableError{err}
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "ecs_exporter/"+version.Version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	// Like Prometheus, retry on server errors and rate limiting only.
+	if resp.StatusCode/100 != 5 && resp.StatusCode != http.StatusTooManyRequests {
+		return unrecoverableError{err}
+	}
+	return err
+}
+
+type unrecoverableError struct {
+	error
+}
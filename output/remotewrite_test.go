@@ -0,0 +1,351 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// remoteWriteReceiver is a stand-in remote write endpoint, which responds
+// to each request with the next of its statuses, then with 204.
+type remoteWriteReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	// series are the received samples, and timeSeries the samples of each
+	// received TimeSeries message.
+	series     []timeSeries
+	timeSeries [][]timeSeries
+}
+
+func newRemoteWriteReceiver(t *testing.T, statuses ...int) *remoteWriteReceiver {
+	r := &remoteWriteReceiver{statuses: statuses}
+	desc := writeRequestDescriptor(t)
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, req)
+		if len(r.statuses) > 0 {
+			status := r.statuses[0]
+			r.statuses = r.statuses[1:]
+			if status/100 != 2 {
+				http.Error(w, "nope", status)
+				return
+			}
+		}
+		compressed, _ := io.ReadAll(req.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("failed to decompress request: %s", err)
+		}
+		series, err := unmarshalWriteRequest(desc, body)
+		if err != nil {
+			t.Errorf("failed to decode request: %s", err)
+		}
+		for _, samples := range series {
+			r.series = append(r.series, samples...)
+		}
+		r.timeSeries = append(r.timeSeries, series...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// remoteWriteProto is the remote write 1.0 schema, transcribed from
+// prompb/remote.proto and prompb/types.proto of prometheus/prometheus, so
+// that requests are decoded independently of the field numbers of prompb.go.
+// Exemplars, native histograms and metadata are left out.
+const remoteWriteProto = `
+name: "prometheus/remote.proto"
+package: "prometheus"
+syntax: "proto3"
+message_type: {
+  name: "WriteRequest"
+  field: {name: "timeseries" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".prometheus.TimeSeries"}
+}
+message_type: {
+  name: "TimeSeries"
+  field: {name: "labels" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".prometheus.Label"}
+  field: {name: "samples" number: 2 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".prometheus.Sample"}
+}
+message_type: {
+  name: "Label"
+  field: {name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING}
+  field: {name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING}
+}
+message_type: {
+  name: "Sample"
+  field: {name: "value" number: 1 label: LABEL_OPTIONAL type: TYPE_DOUBLE}
+  field: {name: "timestamp" number: 2 label: LABEL_OPTIONAL type: TYPE_INT64}
+}
+`
+
+// writeRequestDescriptor returns the descriptor of prometheus.WriteRequest.
+func writeRequestDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	var fdp descriptorpb.FileDescriptorProto
+	if err := prototext.Unmarshal([]byte(remoteWriteProto), &fdp); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := protodesc.NewFile(&fdp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().ByName("WriteRequest")
+}
+
+// unmarshalWriteRequest decodes a prometheus.WriteRequest message into its
+// series, each with its samples. Fields of the wrong wire type are decoded
+// as unknown fields, which are reported as errors.
+func unmarshalWriteRequest(desc protoreflect.MessageDescriptor, b []byte) ([][]timeSeries, error) {
+	req := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(b, req); err != nil {
+		return nil, err
+	}
+	if err := checkKnownFields(req); err != nil {
+		return nil, err
+	}
+	var series [][]timeSeries
+	tss := req.Get(desc.Fields().ByName("timeseries")).List()
+	for i := range tss.Len() {
+		ts := tss.Get(i).Message()
+		var labels []label
+		ls := ts.Get(ts.Descriptor().Fields().ByName("labels")).List()
+		for j := range ls.Len() {
+			l := ls.Get(j).Message()
+			fields := l.Descriptor().Fields()
+			labels = append(labels, label{l.Get(fields.ByName("name")).String(), l.Get(fields.ByName("value")).String()})
+		}
+		var samples []timeSeries
+		ss := ts.Get(ts.Descriptor().Fields().ByName("samples")).List()
+		for j := range ss.Len() {
+			s := ss.Get(j).Message()
+			fields := s.Descriptor().Fields()
+			samples = append(samples, timeSeries{
+				labels:      labels,
+				value:       s.Get(fields.ByName("value")).Float(),
+				timestampMs: s.Get(fields.ByName("timestamp")).Int(),
+			})
+		}
+		series = append(series, samples)
+	}
+	return series, nil
+}
+
+// checkKnownFields returns an error if m or any message in it has unknown
+// fields.
+func checkKnownFields(m protoreflect.Message) error {
+	if len(m.GetUnknown()) > 0 {
+		return fmt.Errorf("%s has unknown fields", m.Descriptor().FullName())
+	}
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() == nil {
+			return true
+		}
+		if fd.IsList() {
+			for i := range v.List().Len() {
+				if err = checkKnownFields(v.List().Get(i).Message()); err != nil {
+					return false
+				}
+			}
+			return true
+		}
+		err = checkKnownFields(v.Message())
+		return err == nil
+	})
+	return err
+}
+
+func (s timeSeries) String() string {
+	var b strings.Builder
+	for i, l := range s.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", l.name, l.value)
+	}
+	fmt.Fprintf(&b, " %v", s.value)
+	return b.String()
+}
+
+func testRemoteWriteConfig(url string) RemoteWriteConfig {
+	cfg := DefaultRemoteWriteConfig
+	cfg.URL = url
+	cfg.Queue.MinBackoff = model.Duration(time.Millisecond)
+	cfg.Queue.MaxBackoff = model.Duration(time.Millisecond)
+	return cfg
+}
+
+func TestRemoteWrite(t *testing.T) {
+	server := fixtureServer(t)
+	mfs, err := fixtureGatherer(t, server).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first request fails, and is retried.
+	receiver := newRemoteWriteReceiver(t, http.StatusServiceUnavailable)
+	cfg := testRemoteWriteConfig(receiver.URL)
+	cfg.ExternalLabels = map[string]string{"job": "ecs"}
+	cfg.Queue.MaxSamplesPerSend = 1
+	rw, err := newRemoteWrite(cfg, NewMetrics())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Push(context.Background(), mfs); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	for _, s := range receiver.series {
+		got[s.String()] = true
+	}
+	for _, want := range []string{
+		`__name__="ecs_container_cpu_usage_seconds_total",container_name="prometheus",job="ecs",task_arn="arn:aws:ecs:us-east-1:829490980523:task/prom-ecs-exporter-sandbox/bae32def0ab64f06818e8862e58f8d6d" 0.9324394920000001`,
+		`__name__="ecs_container_cpu_usage_seconds_total",container_name="ecs-exporter",job="ecs",task_arn="arn:aws:ecs:us-east-1:829490980523:task/prom-ecs-exporter-sandbox/bae32def0ab64f06818e8862e58f8d6d" 0.322633383`,
+	} {
+		if !got[want] {
+			t.Errorf("missing series %s, got %v", want, got)
+		}
+	}
+	// One request per sample, plus the retry.
+	if len(receiver.requests) != len(got)+1 {
+		t.Errorf("got %d requests, want %d", len(receiver.requests), len(got)+1)
+	}
+	req := receiver.requests[len(receiver.requests)-1]
+	if req.Header.Get("Content-Encoding") != "snappy" || req.Header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("got request headers %v, want remote write 1.0 headers", req.Header)
+	}
+}
+
+func TestRemoteWriteQueue(t *testing.T) {
+	server := fixtureServer(t)
+	mfs, err := fixtureGatherer(t, server).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := len(seriesOf(mfs, nil, 0))
+
+	receiver := newRemoteWriteReceiver(t, http.StatusInternalServerError, http.StatusBadRequest)
+	cfg := testRemoteWriteConfig(receiver.URL)
+	cfg.Queue.Capacity = samples
+	metrics := NewMetrics()
+	rw, err := newRemoteWrite(cfg, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped := func() float64 {
+		return testutil.ToFloat64(metrics.dropped.WithLabelValues("remote_write"))
+	}
+
+	// The push times out while retrying, so its samples stay queued.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rw.Push(ctx, mfs); err == nil {
+		t.Fatal("expected push to fail")
+	}
+	if len(rw.queue) != samples || dropped() != 0 {
+		t.Fatalf("got %d queued and %v dropped samples, want %d queued", len(rw.queue), dropped(), samples)
+	}
+
+	// The queue is full, so the oldest samples are dropped. The rest are
+	// rejected as a bad request, which isn't retried.
+	if err := rw.Push(context.Background(), mfs); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("got error %v, want bad request", err)
+	}
+	if len(rw.queue) != 0 || dropped() != float64(2*samples) {
+		t.Errorf("got %d queued and %v dropped samples, want 0 queued and %d dropped", len(rw.queue), dropped(), 2*samples)
+	}
+	if len(receiver.series) != 0 {
+		t.Errorf("got %d received samples, want 0", len(receiver.series))
+	}
+}
+
+func TestRemoteWriteGroupsSamples(t *testing.T) {
+	server := fixtureServer(t)
+	mfs, err := fixtureGatherer(t, server).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := len(seriesOf(mfs, nil, 0))
+	receiver := newRemoteWriteReceiver(t)
+	rw, err := newRemoteWrite(testRemoteWriteConfig(receiver.URL), NewMetrics())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first push times out, so both pushes' samples are sent together.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rw.Push(ctx, mfs); err == nil {
+		t.Fatal("expected push to fail")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := rw.Push(context.Background(), mfs); err != nil {
+		t.Fatal(err)
+	}
+	if len(receiver.timeSeries) != samples {
+		t.Fatalf("got %d series, want %d", len(receiver.timeSeries), samples)
+	}
+	for _, series := range receiver.timeSeries {
+		if len(series) != 2 || series[0].timestampMs >= series[1].timestampMs {
+			t.Errorf("got samples %v, want two in timestamp order", series)
+		}
+	}
+}
+
+func TestRemoteWriteSigV4(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	receiver := newRemoteWriteReceiver(t)
+	cfg := testRemoteWriteConfig(receiver.URL)
+	cfg.SigV4 = &SigV4Config{}
+	rw, err := newRemoteWrite(cfg, NewMetrics())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw.queue = []timeSeries{{labels: []label{{"__name__", "up"}}, value: 1}}
+	if err := rw.Push(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(receiver.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(receiver.requests))
+	}
+	auth := receiver.requests[0].Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/eu-west-1/aps/aws4_request") {
+		t.Errorf("got Authorization header %q, want a SigV4 signature for aps in eu-west-1", auth)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// SigV4Config configures AWS Signature Version 4 signing. Credentials come
+// from the default chain, which includes the task's IAM role.
+type SigV4Config struct {
+	// Region defaults to the AWS_REGION environment variable, which ECS
+	// sets in Fargate tasks.
+	Region string `yaml:"region"`
+	// Service is the signing name of the service.
+	Service string `yaml:"service"`
+}
+
+// sigV4RoundTripper signs requests before sending them with next.
+type sigV4RoundTripper struct {
+	signer      *v4.Signer
+	credentials aws.CredentialsProvider
+	region      string
+	service     string
+	next        http.RoundTripper
+}
+
+func newSigV4RoundTripper(cfg SigV4Config, next http.RoundTripper) (*sigV4RoundTripper, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), awsconfig.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, errors.New("sigv4 requires a region")
+	}
+	if awsCfg.Credentials == nil {
+		return nil, errors.New("sigv4 requires AWS credentials")
+	}
+	service := cfg.Service
+	if service == "" {
+		service = "aps"
+	}
+	return &sigV4RoundTripper{
+		signer:      v4.NewSigner(),
+		credentials: awsCfg.Credentials,
+		region:      awsCfg.Region,
+		service:     service,
+		next:        next,
+	}, nil
+}
+
+func (rt *sigV4RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	// RoundTrippers must not modify the request.
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	creds, err := rt.credentials.Retrieve(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := rt.signer.SignHTTP(req.Context(), creds, req, hex.EncodeToString(hash[:]), rt.service, rt.region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+	return rt.next.RoundTrip(req)
+}
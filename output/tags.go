@@ -0,0 +1,51 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import "github.com/prometheus/common/model"
+
+// TagsConfig configures how the labels of metrics become the tags of outputs
+// which name them differently, like InfluxDB and Graphite.
+type TagsConfig struct {
+	// Prefix is prepended to metric names, e.g. "ecs.".
+	Prefix string `yaml:"prefix"`
+	// Tags are added to every metric, unless it has a label of the same
+	// name.
+	Tags map[string]string `yaml:"tags"`
+	// TagMapping renames labels to tags, e.g. container_name: container.
+	// Labels mapped to "" are dropped.
+	TagMapping map[string]string `yaml:"tag_mapping"`
+}
+
+// tagged returns the prefixed name and the tags of s, which are sorted by
+// label name and leave out empty values.
+func (c TagsConfig) tagged(s timeSeries) (string, []label) {
+	var (
+		name string
+		tags = make([]label, 0, len(s.labels)-1)
+	)
+	for _, l := range s.labels {
+		if l.name == model.MetricNameLabel {
+			name = c.Prefix + l.value
+			continue
+		}
+		if tag, ok := c.TagMapping[l.name]; ok {
+			l.name = tag
+		}
+		if l.name != "" && l.value != "" {
+			tags = append(tags, l)
+		}
+	}
+	return name, tags
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// taskPollInterval is how often the task metadata is checked for the task
+// stopping.
+const taskPollInterval = 5 * time.Second
+
+// watchTaskStop calls stopping once the DesiredStatus of the task becomes
+// STOPPED, which ECS sets before it stops the task's containers, or until
+// ctx is done.
+func watchTaskStop(ctx context.Context, client *ecsmetadata.Client, interval time.Duration, logger *slog.Logger, stopping func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		task, err := client.RetrieveTaskMetadata(ctx)
+		if err != nil {
+			logger.Debug("Error checking whether the task is stopping", "err", err)
+			continue
+		}
+		if task.DesiredStatus == "STOPPED" {
+			logger.Info("Task is stopping, flushing outputs")
+			stopping()
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy scrapes the metrics endpoints of the task's other
+// containers, e.g. applications exposing their own /metrics, and merges
+// their metrics into the exporter's, so that one scrape of the exporter
+// covers the whole task. It also serves those endpoints to Prometheus' HTTP
+// service discovery, for Prometheus to scrape them directly instead.
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Config configures the targets to scrape.
+type Config struct {
+	// Timeout limits each scrape of the targets.
+	Timeout model.Duration `yaml:"timeout"`
+	// Targets are scraped on every scrape of the exporter.
+	Targets []TargetConfig `yaml:"targets"`
+	// DiscoverContainers also scrapes the running containers of the task
+	// with a prometheus.io/port Docker label.
+	DiscoverContainers bool `yaml:"discover_containers"`
+}
+
+// DefaultConfig is the default Config, which fields left out of it in YAML
+// default to.
+var DefaultConfig = Config{
+	Timeout: model.Duration(5 * time.Second),
+}
+
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	if c.Timeout <= 0 {
+		return errors.New("proxy requires a positive timeout")
+	}
+	return nil
+}
+
+// TargetConfig is a metrics endpoint to scrape.
+type TargetConfig struct {
+	// URL is the endpoint, e.g. http://localhost:8080/metrics.
+	URL string `yaml:"url"`
+	// Labels are added to the target's metrics, unless already set, e.g.
+	// to tell apart targets exposing the same metrics.
+	Labels map[string]string `yaml:"labels"`
+}
+
+func (c *TargetConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain TargetConfig
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy target url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid proxy target url %q: scheme must be http or https", c.URL)
+	}
+	for name := range c.Labels {
+		if !model.LabelName(name).IsValidLegacy() {
+			return fmt.Errorf("invalid label name %q of proxy target %s", name, c.URL)
+		}
+	}
+	return nil
+}
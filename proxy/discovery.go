@@ -0,0 +1,95 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+)
+
+// Docker labels of containers selecting their metrics endpoint, like the
+// Kubernetes annotations of the same name.
+const (
+	// PortLabel is the container port of the endpoint.
+	PortLabel = "prometheus.io/port"
+	// PathLabel is the path of the endpoint, /metrics by default.
+	PathLabel = "prometheus.io/path"
+	// SchemeLabel is http, the default, or https.
+	SchemeLabel = "prometheus.io/scheme"
+)
+
+// Endpoint is the metrics endpoint of a container, selected by its Docker
+// labels.
+type Endpoint struct {
+	Container *tmdsv4.ContainerResponse
+	// Port is the container port.
+	Port         uint16
+	Scheme, Path string
+}
+
+// URL returns the URL of the endpoint on host.
+func (e Endpoint) URL(host string) string {
+	u := url.URL{Scheme: e.Scheme, Host: net.JoinHostPort(host, strconv.Itoa(int(e.Port))), Path: e.Path}
+	return u.String()
+}
+
+// IPv4Address returns the first IPv4 address of the container, or "" if it
+// has none, e.g. in host network mode.
+func (e Endpoint) IPv4Address() string {
+	for _, n := range e.Container.Networks {
+		if len(n.IPv4Addresses) > 0 {
+			return n.IPv4Addresses[0]
+		}
+	}
+	return ""
+}
+
+// Endpoints returns the metrics endpoints of the running containers of task
+// with a PortLabel. Containers whose labels are invalid are left out, and
+// reported in the returned errors.
+func Endpoints(task *tmdsv4.TaskResponse) ([]Endpoint, []error) {
+	var (
+		endpoints []Endpoint
+		errs      []error
+	)
+	for i := range task.Containers {
+		c := &task.Containers[i]
+		portLabel, ok := c.Labels[PortLabel]
+		if !ok || c.KnownStatus != "RUNNING" {
+			continue
+		}
+		port, err := strconv.ParseUint(portLabel, 10, 16)
+		if err != nil || port == 0 {
+			errs = append(errs, fmt.Errorf("invalid %s label %q of container %s", PortLabel, portLabel, c.Name))
+			continue
+		}
+		e := Endpoint{Container: c, Port: uint16(port), Scheme: "http", Path: "/metrics"}
+		if scheme, ok := c.Labels[SchemeLabel]; ok {
+			if scheme != "http" && scheme != "https" {
+				errs = append(errs, fmt.Errorf("invalid %s label %q of container %s", SchemeLabel, scheme, c.Name))
+				continue
+			}
+			e.Scheme = scheme
+		}
+		if path := c.Labels[PathLabel]; path != "" {
+			e.Path = path
+		}
+		endpoints = append(endpoints, e)
+	}
+	return endpoints, errs
+}
@@ -0,0 +1,327 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/version"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// acceptHeader prefers the protobuf format, which carries created
+// timestamps and native histograms, like Prometheus.
+const acceptHeader = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,text/plain;version=0.0.4;q=0.3`
+
+// Proxy scrapes the targets of a Config.
+type Proxy struct {
+	cfg        Config
+	client     *ecsmetadata.Client
+	taskLabels []string
+	logger     *slog.Logger
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	selfID string
+}
+
+// New returns a Proxy for cfg, adding the named task-level labels to the
+// metrics of the targets. It returns nil if cfg has no targets and doesn't
+// discover any.
+func New(cfg Config, client *ecsmetadata.Client, taskLabels []string, logger *slog.Logger) *Proxy {
+	if len(cfg.Targets) == 0 && !cfg.DiscoverContainers {
+		return nil
+	}
+	return &Proxy{
+		cfg:        cfg,
+		client:     client,
+		taskLabels: taskLabels,
+		logger:     logger,
+		httpClient: &http.Client{},
+	}
+}
+
+// target is a metrics endpoint, with the labels added to its metrics.
+type target struct {
+	url    string
+	labels map[string]string
+}
+
+// result is the outcome of scraping a target.
+type result struct {
+	families []*dto.MetricFamily
+	duration time.Duration
+	err      error
+}
+
+// Gatherer returns a prometheus.Gatherer merging the metrics of the targets
+// of p into those gathered by g, along with the up and scrape duration of
+// each target. A nil p returns g.
+func Gatherer(g prometheus.Gatherer, p *Proxy) prometheus.Gatherer {
+	if p == nil {
+		return g
+	}
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		errs := prometheus.MultiError{}
+		errs.Append(err)
+		merged, mergeErr := merge(mfs, p.scrape())
+		errs.Append(mergeErr)
+		return merged, errs.MaybeUnwrap()
+	})
+}
+
+// scrape scrapes all targets concurrently, and returns their metrics
+// followed by the up and scrape duration metrics.
+func (p *Proxy) scrape() []*dto.MetricFamily {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cfg.Timeout))
+	defer cancel()
+	targets, task := p.targets(ctx)
+
+	results := make([]result, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Go(func() {
+			start := time.Now()
+			families, err := p.scrapeTarget(ctx, t.url)
+			results[i] = result{families: families, duration: time.Since(start), err: err}
+		})
+	}
+	wg.Wait()
+
+	var mfs []*dto.MetricFamily
+	up := &dto.MetricFamily{
+		Name: proto.String("ecs_exporter_target_up"),
+		Help: proto.String("Whether the last scrape of the target was successful."),
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+	duration := &dto.MetricFamily{
+		Name: proto.String("ecs_exporter_target_scrape_duration_seconds"),
+		Help: proto.String("Duration of the last scrape of the target."),
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+	for i, t := range targets {
+		r := results[i]
+		targetLabel := []*dto.LabelPair{{Name: proto.String("target"), Value: proto.String(t.url)}}
+		upValue := 1.0
+		if r.err != nil {
+			upValue = 0
+			p.logger.Debug("Error scraping proxy target", "target", t.url, "err", r.err)
+		}
+		up.Metric = append(up.Metric, &dto.Metric{Label: targetLabel, Gauge: &dto.Gauge{Value: proto.Float64(upValue)}})
+		duration.Metric = append(duration.Metric, &dto.Metric{Label: targetLabel, Gauge: &dto.Gauge{Value: proto.Float64(r.duration.Seconds())}})
+
+		labels := maps.Clone(t.labels)
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		if task != nil {
+			for _, name := range p.taskLabels {
+				if _, ok := labels[name]; !ok {
+					labels[name] = ecscollector.TaskLabelValue(task, name)
+				}
+			}
+		}
+		for _, mf := range r.families {
+			for _, m := range mf.Metric {
+				addLabels(m, labels)
+			}
+			mfs = append(mfs, mf)
+		}
+	}
+	return append(mfs, up, duration)
+}
+
+// targets returns the configured targets, followed by the discovered ones,
+// along with the task metadata if it was needed and could be retrieved.
+func (p *Proxy) targets(ctx context.Context) ([]target, *tmdsv4.TaskResponse) {
+	var targets []target
+	for _, t := range p.cfg.Targets {
+		targets = append(targets, target{url: t.URL, labels: t.Labels})
+	}
+	if len(p.taskLabels) == 0 && !p.cfg.DiscoverContainers {
+		return targets, nil
+	}
+	task, err := p.client.RetrieveTaskMetadata(ctx)
+	if err != nil {
+		p.logger.Error("Error retrieving task metadata for proxy targets", "err", err)
+		return targets, nil
+	}
+	if !p.cfg.DiscoverContainers {
+		return targets, task
+	}
+	endpoints, errs := Endpoints(task)
+	for _, err := range errs {
+		p.logger.Warn("Skipping container", "err", err)
+	}
+	selfID := p.self(ctx)
+	for _, e := range endpoints {
+		// Scraping the exporter's own endpoint would scrape the targets
+		// again, endlessly.
+		if e.Container.ID == selfID {
+			continue
+		}
+		host := e.IPv4Address()
+		if host == "" {
+			host = "localhost"
+		}
+		targets = append(targets, target{url: e.URL(host), labels: map[string]string{"container_name": e.Container.Name}})
+	}
+	return targets, task
+}
+
+// self returns the ID of the exporter's container, or "" if it can't be
+// retrieved yet.
+func (p *Proxy) self(ctx context.Context) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.selfID == "" {
+		if container, err := p.client.RetrieveContainerMetadata(ctx); err == nil {
+			p.selfID = container.ID
+		}
+	}
+	return p.selfID
+}
+
+func (p *Proxy) scrapeTarget(ctx context.Context, url string) ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", acceptHeader)
+	req.Header.Set("User-Agent", "ecs_exporter/"+version.Version)
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", strconv.FormatFloat(time.Until(deadline).Seconds(), 'f', -1, 64))
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned HTTP status %s", resp.Status)
+	}
+	var mfs []*dto.MetricFamily
+	dec := expfmt.NewDecoder(resp.Body, expfmt.ResponseFormat(resp.Header))
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				return mfs, nil
+			}
+			return nil, err
+		}
+		mfs = append(mfs, mf)
+	}
+}
+
+// addLabels adds labels to m, unless already set, keeping its labels sorted.
+func addLabels(m *dto.Metric, labels map[string]string) {
+	for name, value := range labels {
+		if value == "" || slices.ContainsFunc(m.Label, func(lp *dto.LabelPair) bool { return lp.GetName() == name }) {
+			continue
+		}
+		m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	}
+	slices.SortFunc(m.Label, func(a, b *dto.LabelPair) int {
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+}
+
+// merge merges the families of targets into mfs, sorted like a registry
+// would. Families of the same name must be of the same type, and keep the
+// help of the first one. Families of
+// another type, and metrics with the same labels as another, e.g. the Go
+// runtime metrics of a target without labels to tell it apart, are dropped
+// and reported in the returned error.
+func merge(mfs, targets []*dto.MetricFamily) ([]*dto.MetricFamily, error) {
+	var errs prometheus.MultiError
+	families := make(map[string]*dto.MetricFamily, len(mfs))
+	seen := make(map[string]bool)
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+		for _, m := range mf.Metric {
+			seen[seriesKey(mf.GetName(), m.Label)] = true
+		}
+	}
+	for _, mf := range targets {
+		family, ok := families[mf.GetName()]
+		if !ok {
+			family = &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type, Unit: mf.Unit}
+			families[mf.GetName()] = family
+		}
+		if family.GetType() != mf.GetType() {
+			errs.Append(fmt.Errorf("proxied metric family %s of type %s conflicts with one of type %s", mf.GetName(), mf.GetType(), family.GetType()))
+			continue
+		}
+		for _, m := range mf.Metric {
+			key := seriesKey(mf.GetName(), m.Label)
+			if seen[key] {
+				errs.Append(fmt.Errorf("dropping duplicate proxied metric %s", key))
+				continue
+			}
+			seen[key] = true
+			family.Metric = append(family.Metric, m)
+		}
+	}
+	out := make([]*dto.MetricFamily, 0, len(families))
+	for _, name := range slices.Sorted(maps.Keys(families)) {
+		family := families[name]
+		if len(family.Metric) == 0 {
+			continue
+		}
+		slices.SortFunc(family.Metric, func(a, b *dto.Metric) int {
+			return slices.CompareFunc(a.Label, b.Label, func(a, b *dto.LabelPair) int {
+				if c := strings.Compare(a.GetName(), b.GetName()); c != 0 {
+					return c
+				}
+				return strings.Compare(a.GetValue(), b.GetValue())
+			})
+		})
+		out = append(out, family)
+	}
+	return out, errs.MaybeUnwrap()
+}
+
+func seriesKey(name string, labels []*dto.LabelPair) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, lp := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", lp.GetName(), lp.GetValue())
+	}
+	b.WriteByte('}')
+	return b.String()
+}
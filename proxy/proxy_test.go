@@ -0,0 +1,143 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
+)
+
+func metricsServer(t *testing.T, path, body string) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestGatherer(t *testing.T) {
+	server, err := ecsmetadatatest.NewServer(loadFixture(t, "fargate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if err := server.SetSelf("ecs-exporter"); err != nil {
+		t.Fatal(err)
+	}
+
+	configured := metricsServer(t, "/metrics", `# HELP app_requests_total Requests served.
+# TYPE app_requests_total counter
+app_requests_total{path="/"} 3
+# HELP go_goroutines Goroutines of the app.
+# TYPE go_goroutines gauge
+go_goroutines 7
+`)
+	discovered := metricsServer(t, "/custom", `# HELP app_requests_total Requests served.
+# TYPE app_requests_total counter
+app_requests_total{path="/"} 5
+`)
+	// Only the prometheus container is discovered: the exporter's own
+	// container is skipped even though it has a port label.
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(discovered.URL, "http://"))
+	server.Update(func(f *ecsmetadatatest.Fixture) {
+		for i := range f.TaskMetadata.Containers {
+			c := &f.TaskMetadata.Containers[i]
+			c.Labels[PortLabel] = port
+			c.Labels[PathLabel] = "/custom"
+			c.Networks[0].IPv4Addresses = []string{"127.0.0.1"}
+		}
+	})
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	cfg := DefaultConfig
+	cfg.Timeout = model.Duration(time.Second)
+	cfg.DiscoverContainers = true
+	cfg.Targets = []TargetConfig{
+		{URL: configured.URL + "/metrics", Labels: map[string]string{"container_name": "app"}},
+		{URL: failing.URL + "/metrics"},
+	}
+	p := New(cfg, server.Client(), []string{"cluster_name"}, slog.Default())
+
+	exporter := prometheus.NewRegistry()
+	exporter.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "go_goroutines", Help: "Number of goroutines that currently exist."}, func() float64 { return 12 }))
+
+	discoveredURL := (&url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", port), Path: "/custom"}).String()
+	want := fmt.Sprintf(`# HELP app_requests_total Requests served.
+# TYPE app_requests_total counter
+app_requests_total{cluster_name="prom-ecs-exporter-sandbox",container_name="app",path="/"} 3
+app_requests_total{cluster_name="prom-ecs-exporter-sandbox",container_name="prometheus",path="/"} 5
+# HELP ecs_exporter_target_up Whether the last scrape of the target was successful.
+# TYPE ecs_exporter_target_up gauge
+ecs_exporter_target_up{target=%q} 1
+ecs_exporter_target_up{target=%q} 0
+ecs_exporter_target_up{target=%q} 1
+# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 12
+go_goroutines{cluster_name="prom-ecs-exporter-sandbox",container_name="app"} 7
+`, configured.URL+"/metrics", failing.URL+"/metrics", discoveredURL)
+	if err := testutil.GatherAndCompare(Gatherer(exporter, p), strings.NewReader(want), "app_requests_total", "ecs_exporter_target_up", "go_goroutines"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	parse := func(text string) []*dto.MetricFamily {
+		t.Helper()
+		parser := expfmt.NewTextParser(model.UTF8Validation)
+		families, err := parser.TextToMetricFamilies(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.SortedFunc(maps.Values(families), func(a, b *dto.MetricFamily) int {
+			return strings.Compare(a.GetName(), b.GetName())
+		})
+	}
+	merged, err := merge(
+		parse("# TYPE a gauge\na 1\n# TYPE b gauge\nb 1\n"),
+		parse("# TYPE a gauge\na 2\na{x=\"y\"} 2\n# TYPE b counter\nb 2\n"),
+	)
+	if err == nil || !strings.Contains(err.Error(), "duplicate") || !strings.Contains(err.Error(), "conflicts") {
+		t.Errorf("got error %v, want the duplicate a and the conflicting b reported", err)
+	}
+	if len(merged) != 2 || len(merged[0].Metric) != 2 || len(merged[1].Metric) != 1 {
+		t.Errorf("got %v, want a 1, a{x=\"y\"} 2 and b 1", merged)
+	}
+}
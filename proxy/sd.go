@@ -0,0 +1,157 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	tmdsv4 "github.com/aws/amazon-ecs-agent/ecs-agent/tmds/handlers/v4/state"
+	"github.com/prometheus/common/model"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+)
+
+// metaLabelPrefix prefixes the labels of discovered targets, which
+// Prometheus drops after relabeling unless they are relabeled to other
+// labels.
+const metaLabelPrefix = model.MetaLabelPrefix + "ecs_"
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// TargetGroup is a group of targets in the format of Prometheus' HTTP service
+// discovery.
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// TargetGroups returns a TargetGroup for each metrics endpoint of task, on
+// the address it can be scraped on from outside the task. That is the
+// container's IP address in awsvpc network mode, and hostAddress with the
+// mapped host port in bridge network mode, unless the port is mapped to a
+// specific address, or hostAddress and the container port in host network
+// mode. Endpoints without an address, e.g. because hostAddress is empty, are
+// left out and reported in the returned errors.
+//
+// Each group has __meta_ecs_* labels for the task-level labels of metrics,
+// e.g. __meta_ecs_cluster_name, and for the container:
+// __meta_ecs_container_name, __meta_ecs_container_id,
+// __meta_ecs_container_image, __meta_ecs_network_mode and
+// __meta_ecs_container_label_<Docker label> for each Docker label, sanitized.
+// The path and scheme of the endpoint are set as __metrics_path__ and
+// __scheme__.
+func TargetGroups(task *tmdsv4.TaskResponse, hostAddress string) ([]TargetGroup, []error) {
+	endpoints, errs := Endpoints(task)
+	groups := []TargetGroup{}
+	for _, e := range endpoints {
+		address, networkMode, err := targetAddress(e, hostAddress)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		labels := map[string]string{
+			model.MetricsPathLabel:              e.Path,
+			model.SchemeLabel:                   e.Scheme,
+			metaLabelPrefix + "container_name":  e.Container.Name,
+			metaLabelPrefix + "container_id":    e.Container.ID,
+			metaLabelPrefix + "container_image": e.Container.Image,
+			metaLabelPrefix + "network_mode":    networkMode,
+		}
+		for _, name := range ecscollector.TaskLabels() {
+			if value := ecscollector.TaskLabelValue(task, name); value != "" {
+				labels[metaLabelPrefix+name] = value
+			}
+		}
+		for name, value := range e.Container.Labels {
+			labels[metaLabelPrefix+"container_label_"+invalidLabelChars.ReplaceAllString(name, "_")] = value
+		}
+		groups = append(groups, TargetGroup{Targets: []string{address}, Labels: labels})
+	}
+	return groups, errs
+}
+
+// targetAddress returns the address e can be scraped on from outside the
+// task, along with the network mode of its container.
+func targetAddress(e Endpoint, hostAddress string) (string, string, error) {
+	networkMode := ""
+	if len(e.Container.Networks) > 0 {
+		networkMode = e.Container.Networks[0].NetworkMode
+	}
+	switch networkMode {
+	case "bridge":
+		for _, p := range e.Container.Ports {
+			if p.ContainerPort != e.Port || p.HostPort == 0 || (p.Protocol != "" && p.Protocol != "tcp") {
+				continue
+			}
+			host := p.HostIp
+			if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+				host = hostAddress
+			}
+			if host == "" {
+				break
+			}
+			return net.JoinHostPort(host, strconv.Itoa(int(p.HostPort))), networkMode, nil
+		}
+		return "", networkMode, fmt.Errorf("no host port mapped to port %d of container %s on a known host address", e.Port, e.Container.Name)
+	case "host":
+		if hostAddress == "" {
+			return "", networkMode, fmt.Errorf("unknown host address of container %s in host network mode", e.Container.Name)
+		}
+		return net.JoinHostPort(hostAddress, strconv.Itoa(int(e.Port))), networkMode, nil
+	}
+	ip := e.IPv4Address()
+	if ip == "" {
+		return "", networkMode, fmt.Errorf("no IPv4 address for container %s", e.Container.Name)
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(int(e.Port))), networkMode, nil
+}
+
+// SDHandler serves the TargetGroups of the task as JSON, for Prometheus'
+// http_sd_configs.
+type SDHandler struct {
+	client      *ecsmetadata.Client
+	hostAddress string
+	logger      *slog.Logger
+}
+
+// NewSDHandler returns an SDHandler for the task of client, whose containers
+// in bridge or host network mode are reachable on hostAddress.
+func NewSDHandler(client *ecsmetadata.Client, hostAddress string, logger *slog.Logger) *SDHandler {
+	return &SDHandler{client: client, hostAddress: hostAddress, logger: logger}
+}
+
+func (h *SDHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	task, err := h.client.RetrieveTaskMetadata(r.Context())
+	if err != nil {
+		// Prometheus keeps the previous targets until this succeeds again.
+		h.logger.Error("Error retrieving task metadata for service discovery", "err", err)
+		http.Error(w, fmt.Sprintf("failed to retrieve task metadata: %s", err), http.StatusInternalServerError)
+		return
+	}
+	groups, errs := TargetGroups(task, h.hostAddress)
+	for _, err := range errs {
+		h.logger.Debug("Skipping service discovery target", "err", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		h.logger.Error("Error writing service discovery response", "err", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
+)
+
+func loadFixture(t *testing.T, name string) *ecsmetadatatest.Fixture {
+	t.Helper()
+	fixture, err := ecsmetadatatest.LoadFixture(
+		"../ecscollector/testdata/fixtures/"+name+"_task_metadata.json",
+		"../ecscollector/testdata/fixtures/"+name+"_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fixture
+}
+
+// setPortLabels sets the Docker labels selecting a metrics endpoint on the
+// named containers.
+func setPortLabels(f *ecsmetadatatest.Fixture, labels map[string]map[string]string) {
+	for i := range f.TaskMetadata.Containers {
+		c := &f.TaskMetadata.Containers[i]
+		for name, value := range labels[c.Name] {
+			c.Labels[name] = value
+		}
+	}
+}
+
+func TestTargetGroups(t *testing.T) {
+	for name, tc := range map[string]struct {
+		fixture     string
+		hostAddress string
+		labels      map[string]map[string]string
+		want        map[string]string
+		errs        int
+	}{
+		"awsvpc": {
+			fixture: "fargate",
+			labels: map[string]map[string]string{
+				"prometheus":   {PortLabel: "9090", PathLabel: "/prometheus/metrics"},
+				"nonessential": {PortLabel: "8080"},
+			},
+			// The stopped nonessential container is left out.
+			want: map[string]string{"prometheus": "10.0.117.145:9090"},
+		},
+		"bridge": {
+			fixture:     "ec2",
+			hostAddress: "10.0.0.5",
+			labels: map[string]map[string]string{
+				"ecs-exporter": {PortLabel: "9779", SchemeLabel: "https"},
+				"prometheus":   {PortLabel: "9090"},
+			},
+			// Port 9090 of the prometheus container isn't mapped.
+			want: map[string]string{"ecs-exporter": "10.0.0.5:32768"},
+			errs: 1,
+		},
+		"bridge without host address": {
+			fixture: "ec2",
+			labels:  map[string]map[string]string{"ecs-exporter": {PortLabel: "9779"}},
+			want:    map[string]string{},
+			errs:    1,
+		},
+		"invalid port": {
+			fixture: "fargate",
+			labels:  map[string]map[string]string{"prometheus": {PortLabel: "http"}},
+			want:    map[string]string{},
+			errs:    1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := loadFixture(t, tc.fixture)
+			setPortLabels(f, tc.labels)
+			groups, errs := TargetGroups(f.TaskMetadata, tc.hostAddress)
+			if len(errs) != tc.errs {
+				t.Errorf("got errors %v, want %d", errs, tc.errs)
+			}
+			got := make(map[string]string)
+			for _, g := range groups {
+				if len(g.Targets) != 1 {
+					t.Fatalf("got targets %v, want one per group", g.Targets)
+				}
+				got[g.Labels["__meta_ecs_container_name"]] = g.Targets[0]
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got targets %v, want %v", got, tc.want)
+			}
+			for container, target := range tc.want {
+				if got[container] != target {
+					t.Errorf("got target %q for %s, want %q", got[container], container, target)
+				}
+			}
+		})
+	}
+}
+
+func TestSDHandler(t *testing.T) {
+	f := loadFixture(t, "fargate")
+	setPortLabels(f, map[string]map[string]string{"prometheus": {PortLabel: "9090", PathLabel: "/prometheus/metrics"}})
+	server, err := ecsmetadatatest.NewServer(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	rec := httptest.NewRecorder()
+	NewSDHandler(server.Client(), "", slog.Default()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sd", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got status %d and content type %q, want 200 and JSON", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var groups []TargetGroup
+	if err := json.Unmarshal(rec.Body.Bytes(), &groups); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("got %d target groups, want 1", len(groups))
+	}
+	for name, want := range map[string]string{
+		"__metrics_path__":                              "/prometheus/metrics",
+		"__scheme__":                                    "http",
+		"__meta_ecs_cluster_name":                       "prom-ecs-exporter-sandbox",
+		"__meta_ecs_family":                             "prom-ecs-exporter-sandbox-main-fargate",
+		"__meta_ecs_revision":                           "9",
+		"__meta_ecs_container_name":                     "prometheus",
+		"__meta_ecs_container_image":                    "prom/prometheus:v3.1.0",
+		"__meta_ecs_network_mode":                       "awsvpc",
+		"__meta_ecs_container_label_prometheus_io_port": "9090",
+	} {
+		if got := groups[0].Labels[name]; got != want {
+			t.Errorf("got label %s=%q, want %q", name, got, want)
+		}
+	}
+
+	server.FailRequests(1, http.StatusServiceUnavailable)
+	rec = httptest.NewRecorder()
+	NewSDHandler(server.Client(), "", slog.Default()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sd", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d when the metadata endpoint fails, want 500", rec.Code)
+	}
+}
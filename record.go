@@ -0,0 +1,43 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
+)
+
+// record saves the raw responses of the task metadata server as fixtures for
+// ecscollector's snapshot tests.
+func record(logger *slog.Logger, dir, name string, redact bool) error {
+	client, err := ecsmetadata.NewClientFromEnvironment()
+	if err != nil {
+		return err
+	}
+	recording, err := ecsmetadatatest.Record(context.Background(), client, name)
+	if err != nil {
+		return err
+	}
+	if redact {
+		recording.Redact()
+	}
+	files, err := recording.WriteDir(dir)
+	for _, file := range files {
+		logger.Info("Saved fixture", "file", file)
+	}
+	return err
+}
@@ -0,0 +1,320 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package relabel applies Prometheus-style metric relabeling to gathered
+// metrics, for outputs which can't relabel at scrape time. It supports the
+// subset of the actions of Prometheus' metric_relabel_configs which make
+// sense on a single target: replace, keep, drop, labelmap, labeldrop and
+// labelkeep.
+package relabel
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/proto"
+)
+
+// Action is the action of a relabeling rule.
+type Action string
+
+const (
+	// Replace sets TargetLabel to Replacement, with the capture groups of
+	// Regex matched against the source labels expanded. If Regex doesn't
+	// match, nothing happens.
+	Replace Action = "replace"
+	// Keep drops metrics for which Regex doesn't match the source labels.
+	Keep Action = "keep"
+	// Drop drops metrics for which Regex matches the source labels.
+	Drop Action = "drop"
+	// LabelMap copies the labels whose name Regex matches to labels named
+	// after Replacement.
+	LabelMap Action = "labelmap"
+	// LabelDrop removes the labels whose name Regex matches.
+	LabelDrop Action = "labeldrop"
+	// LabelKeep removes the labels whose name Regex doesn't match, other
+	// than the metric name.
+	LabelKeep Action = "labelkeep"
+)
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Config is a relabeling rule, in the format of Prometheus'
+// relabel_config.
+type Config struct {
+	SourceLabels []string `yaml:"source_labels,flow,omitempty"`
+	Separator    string   `yaml:"separator,omitempty"`
+	Regex        Regexp   `yaml:"regex,omitempty"`
+	TargetLabel  string   `yaml:"target_label,omitempty"`
+	Replacement  string   `yaml:"replacement,omitempty"`
+	Action       Action   `yaml:"action,omitempty"`
+}
+
+// DefaultConfig is the default relabeling rule, which fields left out of a
+// Config in YAML default to.
+var DefaultConfig = Config{
+	Separator:   ";",
+	Regex:       MustNewRegexp("(.*)"),
+	Replacement: "$1",
+	Action:      Replace,
+}
+
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultConfig
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// Validate checks that c is a valid rule.
+func (c *Config) Validate() error {
+	if c.Regex.Regexp == nil {
+		return fmt.Errorf("relabel configuration for %s action has no regex", c.Action)
+	}
+	switch c.Action {
+	case Replace:
+		if c.TargetLabel == "" {
+			return fmt.Errorf("relabel configuration for replace action requires target_label")
+		}
+	case Keep, Drop:
+	case LabelMap:
+		if !strings.Contains(c.Replacement, "$") && !labelNameRE.MatchString(c.Replacement) {
+			return fmt.Errorf("%q is invalid replacement for labelmap action", c.Replacement)
+		}
+	case LabelDrop, LabelKeep:
+		if len(c.SourceLabels) > 0 || c.TargetLabel != "" {
+			return fmt.Errorf("%s action requires only regex, and no other fields", c.Action)
+		}
+	default:
+		return fmt.Errorf("unknown relabel action %q", c.Action)
+	}
+	for _, name := range c.SourceLabels {
+		if !labelNameRE.MatchString(name) {
+			return fmt.Errorf("%q is not a valid source label name", name)
+		}
+	}
+	return nil
+}
+
+// Regexp is a regular expression matching whole strings, as in Prometheus.
+type Regexp struct {
+	*regexp.Regexp
+	original string
+}
+
+// NewRegexp compiles s into a Regexp matching whole strings.
+func NewRegexp(s string) (Regexp, error) {
+	re, err := regexp.Compile("^(?:" + s + ")$")
+	return Regexp{Regexp: re, original: s}, err
+}
+
+// MustNewRegexp is like NewRegexp but panics if s doesn't compile.
+func MustNewRegexp(s string) Regexp {
+	re, err := NewRegexp(s)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+func (re *Regexp) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	r, err := NewRegexp(s)
+	if err != nil {
+		return err
+	}
+	*re = r
+	return nil
+}
+
+func (re Regexp) MarshalYAML() (interface{}, error) {
+	return re.original, nil
+}
+
+// Process applies the rules in cfgs in order to labels, which include the
+// metric name as "__name__", and reports whether the metric is kept.
+func Process(labels map[string]string, cfgs ...*Config) bool {
+	for _, cfg := range cfgs {
+		if !process(labels, cfg) {
+			return false
+		}
+	}
+	return true
+}
+
+func process(labels map[string]string, cfg *Config) bool {
+	values := make([]string, len(cfg.SourceLabels))
+	for i, name := range cfg.SourceLabels {
+		values[i] = labels[name]
+	}
+	val := strings.Join(values, cfg.Separator)
+
+	switch cfg.Action {
+	case Drop:
+		if cfg.Regex.MatchString(val) {
+			return false
+		}
+	case Keep:
+		if !cfg.Regex.MatchString(val) {
+			return false
+		}
+	case Replace:
+		indexes := cfg.Regex.FindStringSubmatchIndex(val)
+		if indexes == nil {
+			break
+		}
+		target := string(cfg.Regex.ExpandString(nil, cfg.TargetLabel, val, indexes))
+		if !labelNameRE.MatchString(target) {
+			break
+		}
+		res := cfg.Regex.ExpandString(nil, cfg.Replacement, val, indexes)
+		if len(res) == 0 {
+			delete(labels, target)
+			break
+		}
+		labels[target] = string(res)
+	case LabelMap:
+		for _, name := range sortedNames(labels) {
+			if cfg.Regex.MatchString(name) {
+				labels[cfg.Regex.ReplaceAllString(name, cfg.Replacement)] = labels[name]
+			}
+		}
+	case LabelDrop:
+		for name := range labels {
+			if cfg.Regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+	case LabelKeep:
+		for name := range labels {
+			if name != model.MetricNameLabel && !cfg.Regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+	}
+	return true
+}
+
+func sortedNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Gatherer returns a prometheus.Gatherer applying the rules in cfgs to the
+// metrics gathered by g.
+func Gatherer(g prometheus.Gatherer, cfgs []*Config) prometheus.Gatherer {
+	if len(cfgs) == 0 {
+		return g
+	}
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		relabeled, relabelErr := Apply(mfs, cfgs)
+		if relabelErr != nil {
+			errs := prometheus.MultiError{}
+			errs.Append(err)
+			errs.Append(relabelErr)
+			err = errs.MaybeUnwrap()
+		}
+		return relabeled, err
+	})
+}
+
+// Apply applies the rules in cfgs to each metric of mfs. Metrics renamed by
+// the rules move to the family of their new name, which must be of the same
+// type. Labels whose name starts with "__" are removed after relabeling, like
+// in Prometheus. Metrics which end up with no name, or the same labels as
+// another metric, are dropped and reported in the returned error.
+func Apply(mfs []*dto.MetricFamily, cfgs []*Config) ([]*dto.MetricFamily, error) {
+	var errs prometheus.MultiError
+	families := make(map[string]*dto.MetricFamily)
+	seen := make(map[string]bool)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			labels := map[string]string{model.MetricNameLabel: mf.GetName()}
+			for _, lp := range m.Label {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			if !Process(labels, cfgs...) {
+				continue
+			}
+			name := labels[model.MetricNameLabel]
+			if name == "" {
+				errs.Append(fmt.Errorf("relabeling removed the name of a metric of %s", mf.GetName()))
+				continue
+			}
+			family, ok := families[name]
+			if !ok {
+				family = &dto.MetricFamily{Name: proto.String(name), Help: mf.Help, Type: mf.Type, Unit: mf.Unit}
+				families[name] = family
+			}
+			if family.GetType() != mf.GetType() {
+				errs.Append(fmt.Errorf("relabeling moved a metric of %s %s into %s %s", mf.GetType(), mf.GetName(), family.GetType(), name))
+				continue
+			}
+			m.Label = m.Label[:0]
+			for _, labelName := range sortedNames(labels) {
+				if strings.HasPrefix(labelName, "__") {
+					continue
+				}
+				m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(labelName), Value: proto.String(labels[labelName])})
+			}
+			key := seriesKey(name, m.Label)
+			if seen[key] {
+				errs.Append(fmt.Errorf("relabeling made metrics of %s collide as %s", mf.GetName(), key))
+				continue
+			}
+			seen[key] = true
+			family.Metric = append(family.Metric, m)
+		}
+	}
+
+	out := make([]*dto.MetricFamily, 0, len(families))
+	for _, name := range slices.Sorted(maps.Keys(families)) {
+		family := families[name]
+		sort.Slice(family.Metric, func(i, j int) bool {
+			return seriesKey("", family.Metric[i].Label) < seriesKey("", family.Metric[j].Label)
+		})
+		out = append(out, family)
+	}
+	return out, errs.MaybeUnwrap()
+}
+
+func seriesKey(name string, labels []*dto.LabelPair) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, lp := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", lp.GetName(), lp.GetValue())
+	}
+	b.WriteByte('}')
+	return b.String()
+}
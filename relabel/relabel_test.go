@@ -0,0 +1,198 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relabel
+
+import (
+	"log/slog"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.yaml.in/yaml/v2"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
+)
+
+func loadConfigs(t *testing.T, s string) []*Config {
+	t.Helper()
+	var cfgs []*Config
+	if err := yaml.UnmarshalStrict([]byte(s), &cfgs); err != nil {
+		t.Fatal(err)
+	}
+	return cfgs
+}
+
+func TestProcess(t *testing.T) {
+	input := map[string]string{
+		"__name__":       "ecs_container_cpu_usage_seconds_total",
+		"container_name": "prometheus",
+		"family":         "web",
+	}
+	for _, tc := range []struct {
+		name   string
+		config string
+		want   map[string]string
+	}{
+		{
+			name:   "keep",
+			config: `[{source_labels: [container_name], regex: prom.*, action: keep}]`,
+			want:   input,
+		},
+		{
+			name:   "keep no match",
+			config: `[{source_labels: [container_name], regex: prom, action: keep}]`,
+		},
+		{
+			name:   "drop",
+			config: `[{source_labels: [__name__, container_name], regex: ".*_cpu_.*;prometheus", action: drop}]`,
+		},
+		{
+			name:   "replace",
+			config: `[{source_labels: [family, container_name], regex: "(.*);(.*)", target_label: service, replacement: "$1/$2"}]`,
+			want: map[string]string{
+				"__name__":       "ecs_container_cpu_usage_seconds_total",
+				"container_name": "prometheus",
+				"family":         "web",
+				"service":        "web/prometheus",
+			},
+		},
+		{
+			name:   "replace empty deletes",
+			config: `[{source_labels: [missing], target_label: family}]`,
+			want: map[string]string{
+				"__name__":       "ecs_container_cpu_usage_seconds_total",
+				"container_name": "prometheus",
+			},
+		},
+		{
+			name:   "labelmap",
+			config: `[{regex: "container_(.*)", action: labelmap}]`,
+			want: map[string]string{
+				"__name__":       "ecs_container_cpu_usage_seconds_total",
+				"container_name": "prometheus",
+				"name":           "prometheus",
+				"family":         "web",
+			},
+		},
+		{
+			name:   "labeldrop",
+			config: `[{regex: family, action: labeldrop}]`,
+			want: map[string]string{
+				"__name__":       "ecs_container_cpu_usage_seconds_total",
+				"container_name": "prometheus",
+			},
+		},
+		{
+			name:   "labelkeep",
+			config: `[{regex: family, action: labelkeep}]`,
+			want: map[string]string{
+				"__name__": "ecs_container_cpu_usage_seconds_total",
+				"family":   "web",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			labels := maps.Clone(input)
+			keep := Process(labels, loadConfigs(t, tc.config)...)
+			if keep != (tc.want != nil) {
+				t.Fatalf("got keep %t, want %t", keep, tc.want != nil)
+			}
+			if keep && !maps.Equal(labels, tc.want) {
+				t.Errorf("got labels %v, want %v", labels, tc.want)
+			}
+		})
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for config, want := range map[string]string{
+		`[{action: replace}]`:                            "requires target_label",
+		`[{action: nope}]`:                               `unknown relabel action "nope"`,
+		`[{action: labeldrop, source_labels: [a]}]`:      "requires only regex",
+		`[{action: labelmap, replacement: "not-valid"}]`: "invalid replacement",
+		`[{action: keep, regex: "("}]`:                   "missing closing )",
+	} {
+		var cfgs []*Config
+		err := yaml.UnmarshalStrict([]byte(config), &cfgs)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want one containing %q", config, err, want)
+		}
+	}
+}
+
+// TestGatherer relabels the metrics of a fixture.
+func TestGatherer(t *testing.T) {
+	fixture, err := ecsmetadatatest.LoadFixture(
+		"../ecscollector/testdata/fixtures/fargate_task_metadata.json",
+		"../ecscollector/testdata/fixtures/fargate_task_stats.json",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ecsmetadatatest.NewServer(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	collector, err := ecscollector.NewCollector(server.Client(), slog.Default(), ecscollector.Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	gatherer := Gatherer(registry, loadConfigs(t, `
+- source_labels: [__name__]
+  regex: ecs_container_(cpu|memory_usage)_.*
+  action: keep
+- source_labels: [container_name]
+  regex: ecs-exporter
+  action: drop
+- source_labels: [__name__]
+  regex: ecs_container_(.*)
+  target_label: __name__
+  replacement: ecs_task_container_$1
+- regex: container_name
+  action: labelmap
+  replacement: container
+- regex: container_name
+  action: labeldrop
+`))
+	expected := `
+# HELP ecs_task_container_cpu_usage_seconds_total Cumulative total container CPU usage in seconds.
+# TYPE ecs_task_container_cpu_usage_seconds_total counter
+ecs_task_container_cpu_usage_seconds_total{container="prometheus"} 0.9324394920000001
+# HELP ecs_task_container_memory_usage_bytes Current container memory usage in bytes.
+# TYPE ecs_task_container_memory_usage_bytes gauge
+ecs_task_container_memory_usage_bytes{container="prometheus"} 1.27934464e+08
+`
+	if err := testutil.GatherAndCompare(gatherer, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// Renaming every metric to the same name collides.
+	gatherer = Gatherer(registry, loadConfigs(t, `
+- source_labels: [__name__]
+  regex: ecs_container_cpu_.*
+  action: keep
+- target_label: container_name
+  replacement: all
+`))
+	if _, err := gatherer.Gather(); err == nil || !strings.Contains(err.Error(), "collide") {
+		t.Errorf("got error %v, want a collision", err)
+	}
+}
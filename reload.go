@@ -0,0 +1,180 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus-community/ecs_exporter/config"
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata"
+	"github.com/prometheus-community/ecs_exporter/output"
+	"github.com/prometheus-community/ecs_exporter/proxy"
+)
+
+// reloader builds the ECS collector from the command line flags and the
+// configuration file, and rebuilds it whenever the file is reloaded.
+type reloader struct {
+	path    string
+	flags   ecscollector.Opts
+	client  *ecsmetadata.Client
+	logger  *slog.Logger
+	handler *metricsHandler
+	outputs *output.Manager
+	// agent and docker are set in daemon mode, for the collector to
+	// monitor every task on the container instance.
+	agent  *ecsmetadata.Client
+	docker *ecsmetadata.Client
+
+	mu          sync.Mutex
+	success     prometheus.Gauge
+	successTime prometheus.Gauge
+}
+
+// newReloader returns a reloader for the configuration file at path, applied
+// on top of the options from the command line flags. The caller is expected
+// to exit if the first call to load fails, so the reload metrics start
+// out successful.
+func newReloader(path string, flags ecscollector.Opts, client *ecsmetadata.Client, logger *slog.Logger) *reloader {
+	r := &reloader{
+		path:   path,
+		flags:  flags,
+		client: client,
+		logger: logger,
+		success: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ecs_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful.",
+		}),
+		successTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ecs_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload.",
+		}),
+	}
+	r.success.Set(1)
+	r.successTime.SetToCurrentTime()
+	return r
+}
+
+func (r *reloader) Describe(ch chan<- *prometheus.Desc) {
+	r.success.Describe(ch)
+	r.successTime.Describe(ch)
+}
+
+func (r *reloader) Collect(ch chan<- prometheus.Metric) {
+	r.success.Collect(ch)
+	r.successTime.Collect(ch)
+}
+
+// load loads the configuration file and returns it, empty if there is none,
+// with a new Collector and Proxy for it. It changes nothing in use: the
+// caller applies the cache TTLs with applyCacheTTLs once the rest of the
+// configuration is in place.
+func (r *reloader) load() (*ecscollector.Collector, *proxy.Proxy, *config.Config, error) {
+	cfg := &config.Config{}
+	if r.path != "" {
+		var err error
+		if cfg, err = config.Load(r.path); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	opts := cfg.Apply(r.flags)
+	var (
+		collector *ecscollector.Collector
+		err       error
+	)
+	if r.agent != nil {
+		if len(cfg.Proxy.Targets) > 0 || cfg.Proxy.DiscoverContainers {
+			return nil, nil, nil, errors.New("the proxy is not supported in daemon mode")
+		}
+		collector, err = ecscollector.NewDaemonCollector(r.agent, r.docker, r.client, r.logger, opts)
+	} else {
+		collector, err = ecscollector.NewCollector(r.client, r.logger, opts)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	r.logger.Info("Enabled collectors", "collectors", opts.Collectors)
+	return collector, proxy.New(cfg.Proxy, r.client, opts.TaskLabels, r.logger), cfg, nil
+}
+
+// applyCacheTTLs applies the metadata cache TTLs of cfg to the client.
+func (r *reloader) applyCacheTTLs(cfg *config.Config) {
+	r.client.SetCacheTTL("/task", time.Duration(cfg.Metadata.TaskCacheTTL))
+	r.client.SetCacheTTL("/task/stats", time.Duration(cfg.Metadata.StatsCacheTTL))
+}
+
+// reload replaces the handler's collector and the outputs with those of the
+// current configuration file. On error, the previous ones are kept.
+func (r *reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	collector, p, cfg, err := r.load()
+	if err != nil {
+		r.success.Set(0)
+		r.logger.Error("Error reloading config", "file", r.path, "err", err)
+		return err
+	}
+	if err := r.outputs.Apply(cfg.Outputs); err != nil {
+		r.success.Set(0)
+		r.logger.Error("Error reloading outputs", "file", r.path, "err", err)
+		return err
+	}
+	r.applyCacheTTLs(cfg)
+	r.handler.setCollector(collector, p, cfg.MetricRelabelConfigs)
+	r.success.Set(1)
+	r.successTime.SetToCurrentTime()
+	r.logger.Info("Reloaded config", "file", r.path)
+	return nil
+}
+
+// watchSignals reloads the configuration file on SIGHUP, from the time it
+// returns until stop is called.
+func (r *reloader) watchSignals() (stop func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range hup {
+			_ = r.reload()
+		}
+	}()
+	return func() {
+		signal.Stop(hup)
+		close(hup)
+		<-done
+	}
+}
+
+// ServeHTTP reloads the configuration file on POST /-/reload.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		http.Error(w, "Only POST or PUT requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.reload(); err != nil {
+		http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/prometheus-community/ecs_exporter/ecscollector"
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
+	"github.com/prometheus-community/ecs_exporter/output"
+)
+
+// newTestReloader starts the exporter the way main does, with the
+// configuration file at path and only the container_cpu collector enabled
+// by flags.
+func newTestReloader(t *testing.T, s *ecsmetadatatest.Server, path string) *reloader {
+	t.Helper()
+	logger := slog.Default()
+	r := newReloader(path, ecscollector.Opts{Collectors: []string{"container_cpu"}}, s.Client(), logger)
+	collector, p, cfg, err := r.load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	r.handler = newMetricsHandler(collector, p, cfg.MetricRelabelConfigs, prometheus.NewRegistry(), promhttp.HandlerOpts{})
+	r.outputs = output.NewManager(r.handler, r.client, logger, output.NewMetrics())
+	t.Cleanup(r.outputs.Stop)
+	if err := r.outputs.Apply(cfg.Outputs); err != nil {
+		t.Fatalf("failed to apply outputs: %v", err)
+	}
+	r.applyCacheTTLs(cfg)
+	return r
+}
+
+func writeConfig(t *testing.T, path, cfg string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// postReload sends a POST /-/reload to r and returns the status code.
+func postReload(r *reloader) int {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	return rec.Code
+}
+
+// taskRequests returns how many times scraping r's handler twice queries the
+// task metadata, to tell whether its response is cached.
+func taskRequests(t *testing.T, s *ecsmetadatatest.Server, r *reloader) int {
+	t.Helper()
+	before := s.Requests("/task")
+	for range 2 {
+		if code, body := get(t, r.handler, "/metrics"); code != http.StatusOK {
+			t.Fatalf("got status %d, want %d:\n%s", code, http.StatusOK, body)
+		}
+	}
+	return s.Requests("/task") - before
+}
+
+func TestReload(t *testing.T) {
+	s := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "ecs_exporter.yml")
+	writeConfig(t, path, "{}\n")
+	r := newTestReloader(t, s, path)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d for GET, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	if got := rec.Header().Get("Allow"); got != "POST, PUT" {
+		t.Errorf("got Allow header %q, want %q", got, "POST, PUT")
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		// code is the status of the reload, and metric one exposed after it.
+		code   int
+		metric string
+		// cached tells whether the task metadata is cached after it.
+		cached bool
+	}{
+		{
+			name:   "invalid outputs",
+			config: "collectors: {container_cpu: false, container_memory: true}\nmetadata: {task_cache_ttl: 1h}\noutputs: {otlp: {endpoint: 'http://[::1'}}\n",
+			code:   http.StatusInternalServerError,
+			metric: "ecs_container_cpu_usage_seconds_total",
+		},
+		{
+			name:   "valid",
+			config: "collectors: {container_cpu: false, container_memory: true}\nmetadata: {task_cache_ttl: 1h}\n",
+			code:   http.StatusOK,
+			metric: "ecs_container_memory_usage_bytes",
+			cached: true,
+		},
+		{
+			name:   "invalid file",
+			config: "collectors: {container_cpu: maybe}\n",
+			code:   http.StatusInternalServerError,
+			metric: "ecs_container_memory_usage_bytes",
+			cached: true,
+		},
+		{
+			name:   "unknown collector",
+			config: "collectors: {container_gpu: true}\n",
+			code:   http.StatusInternalServerError,
+			metric: "ecs_container_memory_usage_bytes",
+			cached: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writeConfig(t, path, test.config)
+			before := testutil.ToFloat64(r.successTime)
+			time.Sleep(10 * time.Millisecond)
+			if code := postReload(r); code != test.code {
+				t.Errorf("got status %d, want %d", code, test.code)
+			}
+			success := 0.0
+			if test.code == http.StatusOK {
+				success = 1
+			}
+			if got := testutil.ToFloat64(r.success); got != success {
+				t.Errorf("got ecs_exporter_config_last_reload_successful %v, want %v", got, success)
+			}
+			if after := testutil.ToFloat64(r.successTime); (after > before) != (test.code == http.StatusOK) {
+				t.Errorf("got ecs_exporter_config_last_reload_success_timestamp_seconds %v after %v", after, before)
+			}
+			if _, body := get(t, r.handler, "/metrics"); !strings.Contains(body, test.metric+"{") {
+				t.Errorf("metrics lack %s:\n%s", test.metric, body)
+			}
+			want := 2
+			if test.cached {
+				want = 0
+			}
+			if got := taskRequests(t, s, r); got != want {
+				t.Errorf("two scrapes queried the task metadata %d times, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	s := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "ecs_exporter.yml")
+	writeConfig(t, path, "{}\n")
+	r := newTestReloader(t, s, path)
+	r.success.Set(0)
+	stop := r.watchSignals()
+	defer stop()
+
+	writeConfig(t, path, "collectors: {container_cpu: false, container_memory: true}\n")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(r.success) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("config not reloaded after SIGHUP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, body := get(t, r.handler, "/metrics"); !strings.Contains(body, "ecs_container_memory_usage_bytes{") {
+		t.Errorf("metrics lack those of the reloaded config:\n%s", body)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// drainer keeps metrics served for a while after ECS stops the task, which it
+// does by sending SIGTERM to every container, so that the last seconds of
+// data and the exit codes of the other containers get scraped.
+type drainer struct {
+	window   time.Duration
+	logger   *slog.Logger
+	stopping prometheus.Gauge
+
+	draining    atomic.Bool
+	scraped     chan struct{}
+	scrapedOnce sync.Once
+}
+
+func newDrainer(window time.Duration, logger *slog.Logger) *drainer {
+	return &drainer{
+		window: window,
+		logger: logger,
+		stopping: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ecs_task_stopping",
+			Help: "Whether the task is stopping, i.e. ecs_exporter received SIGTERM and is serving its final scrapes.",
+		}),
+		scraped: make(chan struct{}),
+	}
+}
+
+func (d *drainer) Describe(ch chan<- *prometheus.Desc) {
+	d.stopping.Describe(ch)
+}
+
+func (d *drainer) Collect(ch chan<- prometheus.Metric) {
+	d.stopping.Collect(ch)
+}
+
+// wrap returns a handler serving next, which ends the drain once it has
+// served a full, successful scrape that started after it began, and so
+// reports the task as stopping. Scrapes restricted with collect[] parameters,
+// or which fail, don't count.
+func (d *drainer) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		draining := d.draining.Load()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if draining && sw.status == http.StatusOK && !r.URL.Query().Has("collect[]") {
+			d.scrapedOnce.Do(func() { close(d.scraped) })
+		}
+	})
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// drain marks the task as stopping, and waits until either one more scrape
+// has been served or the drain window has elapsed.
+func (d *drainer) drain() {
+	d.stopping.Set(1)
+	d.draining.Store(true)
+	if d.window <= 0 {
+		return
+	}
+	d.logger.Info("Draining before shutdown", "window", d.window)
+	timer := time.NewTimer(d.window)
+	defer timer.Stop()
+	select {
+	case <-d.scraped:
+		d.logger.Info("Served final scrape")
+	case <-timer.C:
+		d.logger.Info("Drain window elapsed without a scrape")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDrainWindowElapses(t *testing.T) {
+	d := newDrainer(50*time.Millisecond, slog.Default())
+	start := time.Now()
+	d.drain()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("drain returned after %v, want the whole window", elapsed)
+	}
+	if got := testutil.ToFloat64(d.stopping); got != 1 {
+		t.Errorf("got ecs_task_stopping %v, want 1", got)
+	}
+}
+
+func TestDrainFinalScrape(t *testing.T) {
+	d := newDrainer(time.Hour, slog.Default())
+	handler := d.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("fail") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}
+	}))
+	scrape := func(target string) {
+		t.Helper()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	// A scrape before the drain doesn't end it.
+	scrape("/metrics")
+	done := make(chan struct{})
+	go func() {
+		d.drain()
+		close(done)
+	}()
+	for !d.draining.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, target := range []string{"/metrics?fail=1", "/metrics?collect[]=container_cpu"} {
+		scrape(target)
+		select {
+		case <-done:
+			t.Fatalf("drain ended after a scrape of %s", target)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	scrape("/metrics")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain didn't end after a full scrape")
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/prometheus-community/ecs_exporter/ecsmetadata/ecsmetadatatest"
+)
+
+// simulate starts a fake task metadata server serving either a recorded
+// fixture, given as the path prefix of its files, or a YAML task description.
+// Its counters move forward in real time, until ctx is done.
+func simulate(ctx context.Context, logger *slog.Logger, fixture, taskFile, listenAddress string, jitter float64) (*ecsmetadatatest.Server, error) {
+	var (
+		f   *ecsmetadatatest.Fixture
+		err error
+	)
+	switch {
+	case fixture != "" && taskFile != "":
+		return nil, errors.New("only one of --simulate.fixture and --simulate.task-file may be set")
+	case fixture != "":
+		f, err = ecsmetadatatest.LoadFixture(fixture+"_task_metadata.json", fixture+"_task_stats.json")
+	case taskFile != "":
+		var t *ecsmetadatatest.TaskDescription
+		if t, err = ecsmetadatatest.LoadTaskDescription(taskFile); err == nil {
+			f, err = t.Fixture(time.Now())
+		}
+	default:
+		return nil, errors.New("one of --simulate.fixture or --simulate.task-file must be set")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := ecsmetadatatest.NewUnstartedServer(f)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+	server.Listener.Close()
+	server.Listener = listener
+	server.SetJitter(jitter)
+	// Pretend to run as the exporter's container if the task has one, like the
+	// fixtures do, so that --containers.exclude-self can be tried out.
+	_ = server.SetSelf("ecs-exporter")
+	server.Start()
+	logger.Info("Serving simulated task metadata", "endpoint", server.URL)
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case now := <-ticker.C:
+				server.Advance(now.Sub(last))
+				last = now
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return server, nil
+}